@@ -0,0 +1,192 @@
+package channel
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/voicetel/freescout-notifier/internal/models"
+)
+
+// Channel is a destination that ticket notifications are delivered to
+type Channel interface {
+	// Name returns the configured name of the channel
+	Name() string
+	// Send delivers a single ticket notification
+	Send(n Notification) error
+}
+
+// Tester is implemented by channels that can verify their connection
+// without delivering a real ticket notification
+type Tester interface {
+	Test() error
+}
+
+// BatchSender is implemented by channels that can deliver several
+// notifications as a single message, such as the queued burst sent when
+// business hours start. SendBatch reports which notifications were
+// delivered, so when one message of the burst fails only its tickets stay
+// queued.
+type BatchSender interface {
+	SendBatch(ns []Notification) (delivered []bool, err error)
+}
+
+// DigestSender is implemented by channels that can deliver a scheduled
+// summary of every overdue ticket
+type DigestSender interface {
+	SendDigest(d Digest) error
+}
+
+// Resolver is implemented by channels that can close out an alert once the
+// ticket no longer needs attention
+type Resolver interface {
+	Resolve(n Notification) error
+}
+
+// Notification is a ticket alert handed to a channel for delivery. Status
+// is StatusSent for live alerts, StatusQueued for alerts that were held
+// outside business hours and StatusResolved when passed to Resolve.
+type Notification struct {
+	Ticket    models.Ticket
+	Status    models.NotificationStatus
+	TicketURL string
+
+	// Escalation tier name and tone, empty when the ticket has not reached
+	// an escalation tier
+	TierName string
+	Tone     models.Tone
+}
+
+// Describe returns the emoji, action and waiting-for wording used when
+// rendering a notification of the given type
+func Describe(notificationType models.NotificationType) (emoji, action, waitingFor string) {
+	switch notificationType {
+	case models.PendingNoCustomerResponse:
+		return "⏳", "waiting for customer", "customer response"
+	case models.UnassignedConversation:
+		return "📥", "unassigned", "an assignee"
+	case models.ReturnedFromPending:
+		return "🔁", "customer replied while pending", "agent response"
+	default:
+		return "🚨", "needs attention", "agent response"
+	}
+}
+
+// Describe returns the emoji, action and waiting-for wording for the
+// notification, adjusted to the tone of its escalation tier
+func (n Notification) Describe() (emoji, action, waitingFor string) {
+	emoji, action, waitingFor = Describe(n.Ticket.NotificationType)
+	switch n.Tone {
+	case models.ToneWarning:
+		emoji, action = "⚠️", "still "+action
+	case models.ToneUrgent:
+		emoji, action = "🔥", "escalated: "+action
+	}
+	return emoji, action, waitingFor
+}
+
+// Headline returns the title of an alert, such as "🚨 Ticket #123 needs
+// attention", with a badge for VIP customers
+func (n Notification) Headline() string {
+	emoji, _, _ := n.Describe()
+	badge := ""
+	if n.Ticket.VIP {
+		badge = "⭐ VIP "
+	}
+	return fmt.Sprintf("%s %s%s", emoji, badge, n.Title())
+}
+
+// Title returns the headline without emoji, for email subjects and incident
+// summaries
+func (n Notification) Title() string {
+	_, action, _ := n.Describe()
+	if n.Ticket.VIP {
+		return fmt.Sprintf("VIP ticket #%d %s", n.Ticket.ID, action)
+	}
+	return fmt.Sprintf("Ticket #%d %s", n.Ticket.ID, action)
+}
+
+// WaitingTime returns how long the ticket has been waiting as a human-readable string
+func (n Notification) WaitingTime() string {
+	return FormatDuration(time.Duration(n.Ticket.MinutesSinceReply) * time.Minute)
+}
+
+// AssignedTo returns the assignee name or "Unassigned", followed by the
+// Slack user who took the ticket with Assign to me, such as "Unassigned
+// (owner: jane)"
+func (n Notification) AssignedTo() string {
+	name := strings.TrimSpace(n.Ticket.AssignedUserName)
+	if name == "" {
+		name = "Unassigned"
+	}
+	if n.Ticket.OwnerName != "" {
+		return fmt.Sprintf("%s (owner: %s)", name, n.Ticket.OwnerName)
+	}
+	return name
+}
+
+// Mailbox returns the mailbox name, or its ID when the name is unknown
+func (n Notification) Mailbox() string {
+	if n.Ticket.MailboxName != "" {
+		return n.Ticket.MailboxName
+	}
+	return fmt.Sprintf("#%d", n.Ticket.MailboxID)
+}
+
+// Chunk splits notifications into batches of at most size, so a long queued
+// burst is delivered as a few messages instead of one oversized message
+func Chunk(ns []Notification, size int) [][]Notification {
+	var chunks [][]Notification
+	for len(ns) > size {
+		chunks = append(chunks, ns[:size])
+		ns = ns[size:]
+	}
+	if len(ns) > 0 {
+		chunks = append(chunks, ns)
+	}
+	return chunks
+}
+
+// BatchHeadline returns the title of one of the messages of a queued burst
+// of total tickets, such as "🌅 45 tickets waiting since business hours
+// closed (1/3)"
+func BatchHeadline(total, part, parts int) string {
+	headline := fmt.Sprintf("🌅 %d tickets waiting since business hours closed", total)
+	if total == 1 {
+		headline = "🌅 1 ticket waiting since business hours closed"
+	}
+	if parts > 1 {
+		headline += fmt.Sprintf(" (%d/%d)", part, parts)
+	}
+	return headline
+}
+
+// Summary returns a one-line description of the ticket for batched
+// messages, such as "Cannot log in · Support · agent response for 5 hours ·
+// John Smith"
+func (n Notification) Summary() string {
+	_, _, waitingFor := n.Describe()
+	return fmt.Sprintf("%s · %s · %s for %s · %s", n.Ticket.Subject, n.Mailbox(), waitingFor, n.WaitingTime(), n.AssignedTo())
+}
+
+// FormatDuration renders a duration as hours and minutes
+func FormatDuration(d time.Duration) string {
+	if d < time.Hour {
+		return fmt.Sprintf("%d minutes", int(d.Minutes()))
+	}
+
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+
+	if hours == 1 {
+		if minutes == 0 {
+			return "1 hour"
+		}
+		return fmt.Sprintf("1 hour %d minutes", minutes)
+	}
+
+	if minutes == 0 {
+		return fmt.Sprintf("%d hours", hours)
+	}
+	return fmt.Sprintf("%d hours %d minutes", hours, minutes)
+}
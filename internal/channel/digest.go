@@ -0,0 +1,165 @@
+package channel
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/voicetel/freescout-notifier/internal/models"
+)
+
+// Digest is a summary of every ticket that is currently overdue, sent as a
+// single message on a schedule
+type Digest struct {
+	Title   string // Such as "Daily digest"
+	Tickets []DigestTicket
+}
+
+// DigestTicket is an overdue ticket listed in a digest
+type DigestTicket struct {
+	Notification
+	LastAlertedAt *time.Time // When the last alert was sent, nil if never
+}
+
+// DigestGroup is the tickets of a single assignee or mailbox
+type DigestGroup struct {
+	Name    string
+	Tickets []DigestTicket
+	Oldest  time.Duration // Longest wait among the tickets
+}
+
+// Headline returns the title of the digest with the number of tickets of
+// each type, such as "📋 Daily digest: 12 overdue tickets (8 open, 4 pending)"
+func (d Digest) Headline() string {
+	if len(d.Tickets) == 0 {
+		return fmt.Sprintf("📋 %s: no overdue tickets", d.Title)
+	}
+
+	counts := make(map[models.NotificationType]int)
+	for _, t := range d.Tickets {
+		counts[t.Ticket.NotificationType]++
+	}
+	var parts []string
+	for _, notificationType := range models.NotificationTypes {
+		if count := counts[notificationType]; count > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", count, digestLabel(notificationType)))
+		}
+	}
+
+	return fmt.Sprintf("📋 %s: %s (%s)", d.Title, plural(len(d.Tickets), "overdue ticket"), strings.Join(parts, ", "))
+}
+
+// ByAssignee groups the tickets by assignee, most tickets first
+func (d Digest) ByAssignee() []DigestGroup {
+	return d.groupBy(func(t DigestTicket) string { return t.AssignedTo() })
+}
+
+// ByMailbox groups the tickets by mailbox, most tickets first
+func (d Digest) ByMailbox() []DigestGroup {
+	return d.groupBy(func(t DigestTicket) string { return t.Mailbox() })
+}
+
+func (d Digest) groupBy(key func(DigestTicket) string) []DigestGroup {
+	index := make(map[string]int)
+	var groups []DigestGroup
+
+	for _, t := range d.Tickets {
+		name := key(t)
+		i, ok := index[name]
+		if !ok {
+			i = len(groups)
+			index[name] = i
+			groups = append(groups, DigestGroup{Name: name})
+		}
+		groups[i].Tickets = append(groups[i].Tickets, t)
+		if wait := t.Wait(); wait > groups[i].Oldest {
+			groups[i].Oldest = wait
+		}
+	}
+
+	for i := range groups {
+		sort.SliceStable(groups[i].Tickets, func(a, b int) bool {
+			return groups[i].Tickets[a].Wait() > groups[i].Tickets[b].Wait()
+		})
+	}
+	sort.SliceStable(groups, func(a, b int) bool {
+		if len(groups[a].Tickets) != len(groups[b].Tickets) {
+			return len(groups[a].Tickets) > len(groups[b].Tickets)
+		}
+		return groups[a].Name < groups[b].Name
+	})
+	return groups
+}
+
+// Summary returns the group header line, such as "Jane Doe: 3 tickets,
+// oldest waiting 5 hours"
+func (g DigestGroup) Summary() string {
+	return fmt.Sprintf("%s: %s, oldest waiting %s", g.Name, plural(len(g.Tickets), "ticket"), FormatDuration(g.Oldest))
+}
+
+// Wait returns how long the ticket has been waiting
+func (t DigestTicket) Wait() time.Duration {
+	return time.Duration(t.Ticket.MinutesSinceReply) * time.Minute
+}
+
+// Line returns a one-line description of the ticket, such as "#123 Cannot
+// log in (Support) - open for 5 hours, alerted 2 hours ago"
+func (t DigestTicket) Line(now time.Time) string {
+	badge := ""
+	if t.Ticket.VIP {
+		badge = "⭐ "
+	}
+	line := fmt.Sprintf("%s#%d %s (%s) - %s for %s", badge, t.Ticket.ID, t.Ticket.Subject, t.Mailbox(),
+		digestLabel(t.Ticket.NotificationType), t.WaitingTime())
+	if t.LastAlertedAt != nil {
+		line += fmt.Sprintf(", alerted %s ago", FormatDuration(now.Sub(*t.LastAlertedAt).Truncate(time.Minute)))
+	} else {
+		line += ", not alerted yet"
+	}
+	return line
+}
+
+// Text renders the digest as plain text, listing the tickets of each
+// assignee followed by the totals per mailbox
+func (d Digest) Text(now time.Time) string {
+	var text strings.Builder
+	text.WriteString(d.Headline() + "\n")
+
+	for _, g := range d.ByAssignee() {
+		text.WriteString("\n" + g.Summary() + "\n")
+		for _, t := range g.Tickets {
+			fmt.Fprintf(&text, "  - %s\n", t.Line(now))
+		}
+	}
+
+	if mailboxes := d.ByMailbox(); len(mailboxes) > 0 {
+		text.WriteString("\nBy mailbox:\n")
+		for _, g := range mailboxes {
+			fmt.Fprintf(&text, "  - %s\n", g.Summary())
+		}
+	}
+
+	return text.String()
+}
+
+// digestLabel returns the short name of a notification type used in digests
+func digestLabel(notificationType models.NotificationType) string {
+	switch notificationType {
+	case models.PendingNoCustomerResponse:
+		return "pending"
+	case models.UnassignedConversation:
+		return "unassigned"
+	case models.ReturnedFromPending:
+		return "returned"
+	default:
+		return "open"
+	}
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
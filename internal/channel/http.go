@@ -0,0 +1,79 @@
+package channel
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// permanentError marks an error that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that Retry stops immediately
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// Retry calls fn up to attempts times, backing off between attempts, and
+// returns the last error if every attempt fails
+func Retry(attempts int, fn func() error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			// Exponential backoff
+			time.Sleep(time.Duration(attempt*attempt) * time.Second)
+		}
+
+		lastErr = fn()
+		if lastErr == nil {
+			return nil
+		}
+
+		var permanent *permanentError
+		if errors.As(lastErr, &permanent) {
+			return permanent.err
+		}
+	}
+
+	return fmt.Errorf("failed after %d attempts: %w", attempts, lastErr)
+}
+
+// PostJSON posts a JSON payload to url, retrying until a 2xx response is
+// received or the attempts are exhausted
+func PostJSON(client *http.Client, url string, payload []byte, headers map[string]string, attempts int) error {
+	return Retry(attempts, func() error {
+		req, err := http.NewRequest("POST", url, bytes.NewReader(payload))
+		if err != nil {
+			return Permanent(fmt.Errorf("failed to create request: %w", err))
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+		}
+		return nil
+	})
+}
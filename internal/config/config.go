@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -17,18 +18,53 @@ type Config struct {
 	// FreeScout
 	FreeScout FreeScoutConfig `json:"freescout"`
 
+	// Mailboxes and assignees to watch
+	Filters FilterConfig `json:"filters"`
+
 	// Slack
 	Slack SlackConfig `json:"slack"`
 
+	// Notification channels (the legacy Slack webhook is used when empty)
+	Channels []ChannelConfig `json:"channels"`
+
+	// Routing of alerts to channels
+	Routing RoutingConfig `json:"routing"`
+
 	// Notification Rules
-	OpenThreshold    Duration `json:"open_threshold"`
-	PendingThreshold Duration `json:"pending_threshold"`
-	CooldownPeriod   Duration `json:"cooldown_period"`
-	MaxNotifications int      `json:"max_notifications"`
+	OpenThreshold       Duration `json:"open_threshold"`
+	PendingThreshold    Duration `json:"pending_threshold"`
+	UnassignedThreshold Duration `json:"unassigned_threshold"` // Time without an assignee, 0 disables
+	ReturnedThreshold   Duration `json:"returned_threshold"`   // Time a customer reply to a pending ticket goes unanswered, 0 disables
+	CooldownPeriod      Duration `json:"cooldown_period"`
+	MaxNotifications    int      `json:"max_notifications"`
+
+	// VIP customers
+	VIP VIPConfig `json:"vip"`
+
+	// FreeScout conversation tags
+	Tags TagsConfig `json:"tags"`
+
+	// Per-mailbox overrides of the notification rules, keyed by FreeScout mailbox ID
+	Mailboxes map[int]MailboxRules `json:"mailboxes"`
+
+	// Escalation ladder, ordered by increasing wait time
+	Escalation []EscalationTier `json:"escalation"`
+
+	// Rules evaluated in order against every ticket; the first match applies
+	Rules []RuleConfig `json:"rules"`
 
 	// Business Hours
 	BusinessHours BusinessHoursConfig `json:"business_hours"`
 
+	// Scheduled summary of every overdue ticket
+	Digest DigestConfig `json:"digest"`
+
+	// Interaction server
+	Server ServerConfig `json:"server"`
+
+	// Long-running scheduler used with --daemon
+	Daemon DaemonConfig `json:"daemon"`
+
 	// Cleanup
 	RetentionDays int  `json:"retention_days"`
 	AutoVacuum    bool `json:"auto_vacuum"`
@@ -43,6 +79,9 @@ type Config struct {
 	StatsOnly        bool   `json:"-"`
 	Cleanup          bool   `json:"-"`
 	ShowVersion      bool   `json:"-"`
+	Serve            bool   `json:"-"`
+	SendDigest       bool   `json:"-"`
+	RunDaemon        bool   `json:"-"`
 }
 
 type FreeScoutConfig struct {
@@ -55,6 +94,185 @@ type SlackConfig struct {
 	WebhookURL    string   `json:"webhook_url"`
 	Timeout       Duration `json:"timeout"`
 	RetryAttempts int      `json:"retry_attempts"`
+
+	// Bot mode posts through the Web API instead of the webhook, which
+	// allows reminders to be threaded under the original alert
+	BotToken string `json:"bot_token"`
+	Channel  string `json:"channel"` // Channel ID to post to in bot mode
+	APIURL   string `json:"api_url"` // Defaults to https://slack.com/api
+
+	// Interactive adds acknowledge, snooze and assign buttons to alerts,
+	// handled by the --serve endpoint
+	Interactive bool `json:"interactive"`
+
+	// UserMentions maps FreeScout user IDs to Slack member IDs so alerts
+	// mention the assigned agent
+	UserMentions map[int]string `json:"user_mentions"`
+}
+
+// IsConfigured reports whether either the webhook or bot mode is set up
+func (s SlackConfig) IsConfigured() bool {
+	return s.WebhookURL != "" || s.BotToken != ""
+}
+
+type TeamsConfig struct {
+	WebhookURL    string   `json:"webhook_url"`
+	Timeout       Duration `json:"timeout"`
+	RetryAttempts int      `json:"retry_attempts"`
+}
+
+type SMTPConfig struct {
+	Host             string              `json:"host"`
+	Port             int                 `json:"port"`
+	TLS              string              `json:"tls"` // starttls, implicit or none
+	Username         string              `json:"username"`
+	Password         string              `json:"password"`
+	From             string              `json:"from"`
+	To               []string            `json:"to"`                 // Default recipients
+	RecipientsByType map[string][]string `json:"recipients_by_type"` // Recipients per notification type
+	Timeout          Duration            `json:"timeout"`
+	RetryAttempts    int                 `json:"retry_attempts"`
+}
+
+type WebhookConfig struct {
+	URL           string            `json:"url"`
+	Secret        string            `json:"secret"`   // HMAC-SHA256 signing key, required unless Unsigned is set
+	Unsigned      bool              `json:"unsigned"` // Send without a signature, for endpoints that cannot verify one
+	Headers       map[string]string `json:"headers"`  // Extra request headers
+	Timeout       Duration          `json:"timeout"`
+	RetryAttempts int               `json:"retry_attempts"`
+}
+
+type PagerDutyConfig struct {
+	EventsURL     string   `json:"events_url"`  // Defaults to the public Events API v2 endpoint
+	RoutingKey    string   `json:"routing_key"` // Integration key of the PagerDuty service
+	Severity      string   `json:"severity"`    // critical, error, warning or info
+	Timeout       Duration `json:"timeout"`
+	RetryAttempts int      `json:"retry_attempts"`
+}
+
+// Channel types supported in ChannelConfig.Type
+const (
+	ChannelTypeSlack     = "slack"
+	ChannelTypeTeams     = "teams"
+	ChannelTypeSMTP      = "smtp"
+	ChannelTypeWebhook   = "webhook"
+	ChannelTypePagerDuty = "pagerduty"
+)
+
+// ChannelConfig describes a named notification channel. Only the settings
+// block matching Type is used.
+type ChannelConfig struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	BatchQueued bool   `json:"batch_queued"` // Deliver the queued burst as one message where supported
+
+	// Filters limiting which notifications reach the channel
+	NotificationTypes []string `json:"notification_types"` // Empty means all types
+	MinWait           Duration `json:"min_wait"`           // Minimum time the ticket has been waiting
+
+	Slack     *SlackConfig     `json:"slack,omitempty"`
+	Teams     *TeamsConfig     `json:"teams,omitempty"`
+	SMTP      *SMTPConfig      `json:"smtp,omitempty"`
+	Webhook   *WebhookConfig   `json:"webhook,omitempty"`
+	PagerDuty *PagerDutyConfig `json:"pagerduty,omitempty"`
+}
+
+// RoutingConfig selects the channels that receive a ticket's alerts. A ticket
+// whose mailbox has a route goes to the listed channels, any other ticket
+// goes to the default channels. An empty list means every channel.
+type RoutingConfig struct {
+	Default   []string            `json:"default"`
+	Mailboxes map[int][]string    `json:"mailboxes"` // Channel names per FreeScout mailbox ID
+	Tags      map[string][]string `json:"tags"`      // Channel names per tag, taking precedence over mailboxes
+}
+
+// VIPConfig lists the customers whose tickets alert faster and are never
+// held outside business hours
+type VIPConfig struct {
+	Emails           []string `json:"emails"`
+	Domains          []string `json:"domains"`
+	CustomerIDs      []int    `json:"customer_ids"`      // FreeScout customer IDs
+	File             string   `json:"file"`              // JSON file with the same lists, merged with the above
+	OpenThreshold    Duration `json:"open_threshold"`    // 0 uses the regular threshold
+	PendingThreshold Duration `json:"pending_threshold"` // 0 uses the regular threshold
+}
+
+// FilterConfig limits the conversations that are checked. Empty include
+// lists match everything. The user lists only apply to assigned
+// conversations.
+type FilterConfig struct {
+	IncludeMailboxes []int `json:"include_mailboxes"` // Only watch these mailbox IDs
+	ExcludeMailboxes []int `json:"exclude_mailboxes"` // Never watch these mailbox IDs
+	IncludeUsers     []int `json:"include_users"`     // Only watch conversations assigned to these user IDs
+	ExcludeUsers     []int `json:"exclude_users"`     // Never watch conversations assigned to these user IDs
+}
+
+// TagsConfig changes how tickets are alerted based on their FreeScout tags.
+// Tags are matched case-insensitively.
+type TagsConfig struct {
+	Exclude  []string `json:"exclude"`  // Never alert on tickets with these tags
+	Priority []string `json:"priority"` // Alert on these tickets first, even outside business hours
+}
+
+// MailboxRules overrides the notification rules for a single mailbox. Unset
+// values fall back to the global settings.
+type MailboxRules struct {
+	OpenThreshold       Duration `json:"open_threshold"`
+	PendingThreshold    Duration `json:"pending_threshold"`
+	UnassignedThreshold Duration `json:"unassigned_threshold"`
+	ReturnedThreshold   Duration `json:"returned_threshold"`
+	CooldownPeriod      Duration `json:"cooldown_period"`
+}
+
+// EscalationTier is one step of the escalation ladder. Once a ticket has
+// waited After since the last reply it is sent to the tier's channels, worded
+// according to Tone.
+type EscalationTier struct {
+	Name              string   `json:"name"`
+	After             Duration `json:"after"`
+	Channels          []string `json:"channels"`           // Empty means the routed channels
+	Tone              string   `json:"tone"`               // "normal", "warning" or "urgent"
+	NotificationTypes []string `json:"notification_types"` // Empty means all types
+}
+
+// RuleConfig decides how matching tickets are alerted. When is an
+// expression over ticket attributes, see the rules package.
+type RuleConfig struct {
+	Name     string   `json:"name"`
+	When     string   `json:"when"`
+	Action   string   `json:"action"`   // "notify", "suppress" or "escalate"
+	Channels []string `json:"channels"` // Channels for the notify action
+	Tier     int      `json:"tier"`     // 1-based escalation tier for the escalate action
+}
+
+// ServerConfig configures the --serve endpoint receiving Slack button clicks
+type ServerConfig struct {
+	ListenAddr         string `json:"listen_addr"`
+	SlackSigningSecret string `json:"slack_signing_secret"`
+}
+
+// DaemonConfig schedules the checks and cleanups of --daemon mode
+type DaemonConfig struct {
+	Interval        Duration `json:"interval"`         // Time between checks
+	Jitter          Duration `json:"jitter"`           // Random delay of up to this much added to each interval
+	CleanupInterval Duration `json:"cleanup_interval"` // Time between cleanups, 0 disables
+}
+
+// Digest schedules supported in DigestConfig.Schedule
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestConfig schedules a summary of every overdue open and pending ticket.
+// A daily digest is sent every business day and a weekly digest on the first
+// business day of each week, on the first run once business hours have been
+// open for After.
+type DigestConfig struct {
+	Schedule string   `json:"schedule"` // daily, weekly or empty to disable
+	After    Duration `json:"after"`    // Delay after business hours open
+	Channels []string `json:"channels"` // Empty means every channel that supports digests
 }
 
 type BusinessHoursConfig struct {
@@ -64,7 +282,14 @@ type BusinessHoursConfig struct {
 	Timezone     string         `json:"timezone"`
 	WorkDays     []time.Weekday `json:"work_days"`
 	NotifyOnOpen bool           `json:"notify_on_open"`
+	BatchQueued  bool           `json:"batch_queued"` // Deliver the queued burst as grouped messages on every channel that supports it
 	HolidaysFile string         `json:"holidays_file"`
+	AlwaysOpen   bool           `json:"always_open"` // Open around the clock on every day that is not a holiday
+
+	// Schedule lists the business hours of each weekday, such as
+	// "friday": ["08:30-12:00", "13:00-15:00"], replacing StartHour,
+	// EndHour and WorkDays when set
+	Schedule map[string][]TimeRange `json:"schedule"`
 }
 
 func ParseFlags() *Config {
@@ -80,7 +305,7 @@ func ParseFlags() *Config {
 	flag.StringVar(&cfg.DBPath, "db-path", "./notifications.db", "Path to SQLite database")
 
 	// Create temporary duration variables for flag parsing
-	var dbTimeout, fsTimeout, slackTimeout, openThreshold, pendingThreshold, cooldownPeriod time.Duration
+	var dbTimeout, fsTimeout, slackTimeout, openThreshold, pendingThreshold, unassignedThreshold, returnedThreshold, cooldownPeriod, vipOpenThreshold, vipPendingThreshold, digestAfter, daemonInterval, daemonJitter, cleanupInterval time.Duration
 
 	flag.DurationVar(&dbTimeout, "db-timeout", 5*time.Second, "SQLite timeout")
 
@@ -89,26 +314,57 @@ func ParseFlags() *Config {
 	flag.DurationVar(&fsTimeout, "freescout-timeout", 30*time.Second, "FreeScout connection timeout")
 	flag.StringVar(&cfg.FreeScout.URL, "freescout-url", "https://support.example.com", "FreeScout base URL for ticket links (required)")
 
+	// Filter flags
+	includeMailboxes := flag.String("include-mailboxes", "", "Comma-separated mailbox IDs to watch (default all)")
+	excludeMailboxes := flag.String("exclude-mailboxes", "", "Comma-separated mailbox IDs to ignore")
+	includeUsers := flag.String("include-users", "", "Comma-separated user IDs whose assigned conversations are watched (default all)")
+	excludeUsers := flag.String("exclude-users", "", "Comma-separated user IDs whose assigned conversations are ignored")
+
 	// Slack flags
 	flag.StringVar(&cfg.Slack.WebhookURL, "slack-webhook", "", "Slack webhook URL (required)")
 	flag.DurationVar(&slackTimeout, "slack-timeout", 10*time.Second, "Slack request timeout")
 	flag.IntVar(&cfg.Slack.RetryAttempts, "slack-retry-attempts", 3, "Slack retry attempts")
+	flag.StringVar(&cfg.Slack.BotToken, "slack-bot-token", "", "Slack bot token, enables Web API mode instead of the webhook")
+	flag.StringVar(&cfg.Slack.Channel, "slack-channel", "", "Slack channel ID to post to in bot mode")
+	flag.BoolVar(&cfg.Slack.Interactive, "slack-interactive", false, "Add acknowledge, snooze and assign buttons to Slack alerts")
+
+	// Interaction server flags
+	flag.StringVar(&cfg.Server.ListenAddr, "listen-addr", ":8080", "Address for the Slack interaction endpoint")
+	flag.StringVar(&cfg.Server.SlackSigningSecret, "slack-signing-secret", "", "Slack app signing secret for verifying interactions")
 
 	// Notification rules
 	flag.DurationVar(&openThreshold, "open-threshold", 2*time.Hour, "Time before notifying about open tickets")
 	flag.DurationVar(&pendingThreshold, "pending-threshold", 24*time.Hour, "Time before notifying about pending tickets")
+	flag.DurationVar(&unassignedThreshold, "unassigned-threshold", 0, "Time before notifying about unassigned conversations (0 disables)")
+	flag.DurationVar(&returnedThreshold, "returned-threshold", 0, "Time before notifying about unanswered customer replies to pending tickets (0 disables)")
 	flag.DurationVar(&cooldownPeriod, "cooldown-period", 4*time.Hour, "Cooldown between notifications for same ticket")
 	flag.IntVar(&cfg.MaxNotifications, "max-notifications-per-run", 50, "Maximum notifications per run")
 
+	// VIP flags
+	flag.StringVar(&cfg.VIP.File, "vip-file", "", "Path to VIP customers JSON file")
+	flag.DurationVar(&vipOpenThreshold, "vip-open-threshold", 0, "Time before notifying about open VIP tickets (0 uses --open-threshold)")
+	flag.DurationVar(&vipPendingThreshold, "vip-pending-threshold", 0, "Time before notifying about pending VIP tickets (0 uses --pending-threshold)")
+
 	// Business hours flags
 	flag.BoolVar(&cfg.BusinessHours.Enabled, "business-hours-enabled", true, "Enable business hours restrictions")
 	flag.IntVar(&cfg.BusinessHours.StartHour, "business-hours-start", 9, "Business hours start (0-23)")
-	flag.IntVar(&cfg.BusinessHours.EndHour, "business-hours-end", 17, "Business hours end (0-23)")
+	flag.IntVar(&cfg.BusinessHours.EndHour, "business-hours-end", 17, "Business hours end (0-23), at or before the start for hours that run past midnight")
+	flag.BoolVar(&cfg.BusinessHours.AlwaysOpen, "business-hours-always-open", false, "Treat every day except holidays as open around the clock")
 	flag.StringVar(&cfg.BusinessHours.Timezone, "business-hours-timezone", "America/Chicago", "Business hours timezone")
 	workDaysStr := flag.String("business-hours-days", "1,2,3,4,5", "Business days (1=Mon, 7=Sun)")
 	flag.BoolVar(&cfg.BusinessHours.NotifyOnOpen, "notify-on-hours-start", true, "Send queued notifications when business hours start")
+	flag.BoolVar(&cfg.BusinessHours.BatchQueued, "batch-queued", false, "Send the queued notifications as grouped messages instead of one per ticket")
 	flag.StringVar(&cfg.BusinessHours.HolidaysFile, "holidays-file", "", "Path to holidays JSON file")
 
+	// Digest flags
+	flag.StringVar(&cfg.Digest.Schedule, "digest-schedule", "", "Send a digest of overdue tickets: daily or weekly (default disabled)")
+	flag.DurationVar(&digestAfter, "digest-after", 0, "Delay after business hours open before sending the digest")
+
+	// Daemon flags
+	flag.DurationVar(&daemonInterval, "interval", 5*time.Minute, "Time between checks in daemon mode")
+	flag.DurationVar(&daemonJitter, "interval-jitter", 30*time.Second, "Random delay of up to this much added to each interval in daemon mode")
+	flag.DurationVar(&cleanupInterval, "cleanup-interval", 24*time.Hour, "Time between cleanups in daemon mode (0 disables)")
+
 	// Cleanup flags
 	flag.IntVar(&cfg.RetentionDays, "retention-days", 90, "Days to retain notification history")
 	flag.BoolVar(&cfg.AutoVacuum, "auto-vacuum", true, "Automatically vacuum database after cleanup")
@@ -122,6 +378,9 @@ func ParseFlags() *Config {
 	flag.BoolVar(&cfg.InitDB, "init-db", false, "Initialize database and exit")
 	flag.BoolVar(&cfg.StatsOnly, "stats-only", false, "Print statistics and exit")
 	flag.BoolVar(&cfg.Cleanup, "cleanup", false, "Clean up old records and exit")
+	flag.BoolVar(&cfg.Serve, "serve", false, "Run the Slack interaction endpoint")
+	flag.BoolVar(&cfg.SendDigest, "send-digest", false, "Send a digest of overdue tickets now and exit")
+	flag.BoolVar(&cfg.RunDaemon, "daemon", false, "Keep running and check tickets every --interval")
 
 	flag.Parse()
 
@@ -131,7 +390,34 @@ func ParseFlags() *Config {
 	cfg.Slack.Timeout = Duration{Duration: slackTimeout}
 	cfg.OpenThreshold = Duration{Duration: openThreshold}
 	cfg.PendingThreshold = Duration{Duration: pendingThreshold}
+	cfg.UnassignedThreshold = Duration{Duration: unassignedThreshold}
+	cfg.ReturnedThreshold = Duration{Duration: returnedThreshold}
 	cfg.CooldownPeriod = Duration{Duration: cooldownPeriod}
+	cfg.VIP.OpenThreshold = Duration{Duration: vipOpenThreshold}
+	cfg.VIP.PendingThreshold = Duration{Duration: vipPendingThreshold}
+	cfg.Digest.After = Duration{Duration: digestAfter}
+	cfg.Daemon.Interval = Duration{Duration: daemonInterval}
+	cfg.Daemon.Jitter = Duration{Duration: daemonJitter}
+	cfg.Daemon.CleanupInterval = Duration{Duration: cleanupInterval}
+
+	// Parse filter ID lists
+	for _, f := range []struct {
+		name  string
+		value string
+		ids   *[]int
+	}{
+		{"include-mailboxes", *includeMailboxes, &cfg.Filters.IncludeMailboxes},
+		{"exclude-mailboxes", *excludeMailboxes, &cfg.Filters.ExcludeMailboxes},
+		{"include-users", *includeUsers, &cfg.Filters.IncludeUsers},
+		{"exclude-users", *excludeUsers, &cfg.Filters.ExcludeUsers},
+	} {
+		ids, err := parseIDs(f.value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid --%s: %v\n", f.name, err)
+			os.Exit(1)
+		}
+		*f.ids = ids
+	}
 
 	// Load config file if specified - this will override flag values
 	if *configFile != "" {
@@ -187,24 +473,423 @@ func (c *Config) Validate() error {
 	if c.FreeScout.URL == "" {
 		return fmt.Errorf("--freescout-url is required")
 	}
-	if c.Slack.WebhookURL == "" && !c.DryRun && !c.CheckConnections && !c.InitDB && !c.StatsOnly {
-		return fmt.Errorf("--slack-webhook is required")
+	if len(c.ChannelConfigs()) == 0 && !c.DryRun && !c.CheckConnections && !c.InitDB && !c.StatsOnly && !c.Serve {
+		return fmt.Errorf("--slack-webhook, --slack-bot-token or at least one entry in channels is required")
+	}
+	if c.Serve && c.Server.SlackSigningSecret == "" {
+		return fmt.Errorf("--slack-signing-secret is required with --serve")
+	}
+	if c.Slack.BotToken != "" && c.Slack.Channel == "" {
+		return fmt.Errorf("--slack-channel is required with --slack-bot-token")
+	}
+	if err := c.validateChannels(); err != nil {
+		return err
+	}
+	if err := c.validateFilters(); err != nil {
+		return err
+	}
+	if err := c.validateRouting(); err != nil {
+		return err
+	}
+	if err := c.validateDigest(); err != nil {
+		return err
+	}
+	if err := c.validateEscalation(); err != nil {
+		return err
+	}
+	if err := c.validateRules(); err != nil {
+		return err
+	}
+	if c.RunDaemon {
+		if c.Daemon.Interval.Duration < time.Minute {
+			return fmt.Errorf("--interval must be at least 1m")
+		}
+		if c.Daemon.Jitter.Duration < 0 || c.Daemon.CleanupInterval.Duration < 0 {
+			return fmt.Errorf("--interval-jitter and --cleanup-interval must not be negative")
+		}
+	}
+	for mailboxID, rules := range c.Mailboxes {
+		if rules.OpenThreshold.Duration < 0 || rules.PendingThreshold.Duration < 0 ||
+			rules.UnassignedThreshold.Duration < 0 || rules.ReturnedThreshold.Duration < 0 ||
+			rules.CooldownPeriod.Duration < 0 {
+			return fmt.Errorf("mailboxes[%d]: durations must not be negative", mailboxID)
+		}
 	}
 
 	// Validate business hours
-	if c.BusinessHours.StartHour < 0 || c.BusinessHours.StartHour > 23 {
-		return fmt.Errorf("--business-hours-start must be 0-23")
+	if len(c.BusinessHours.Schedule) > 0 {
+		if _, err := c.BusinessHours.WeeklySchedule(); err != nil {
+			return err
+		}
+	} else {
+		if c.BusinessHours.StartHour < 0 || c.BusinessHours.StartHour > 23 {
+			return fmt.Errorf("--business-hours-start must be 0-23")
+		}
+		if c.BusinessHours.EndHour < 0 || c.BusinessHours.EndHour > 23 {
+			return fmt.Errorf("--business-hours-end must be 0-23")
+		}
+	}
+
+	return nil
+}
+
+// ChannelConfigs returns the configured notification channels. When no
+// channels are listed, the legacy --slack-webhook or --slack-bot-token
+// setting is used as a single channel named "slack".
+func (c *Config) ChannelConfigs() []ChannelConfig {
+	if len(c.Channels) == 0 {
+		if !c.Slack.IsConfigured() {
+			return nil
+		}
+		slack := c.Slack
+		legacy := ChannelConfig{Name: "slack", Type: ChannelTypeSlack, Slack: &slack}
+		return []ChannelConfig{legacy.withDefaults()}
+	}
+
+	channels := make([]ChannelConfig, 0, len(c.Channels))
+	for _, ch := range c.Channels {
+		channels = append(channels, ch.withDefaults())
+	}
+	return channels
+}
+
+// withDefaults fills in the timeout and retry settings left empty in a
+// channel's settings block
+func (ch ChannelConfig) withDefaults() ChannelConfig {
+	if ch.Slack != nil {
+		slack := *ch.Slack
+		if slack.Timeout.Duration == 0 {
+			slack.Timeout = Duration{Duration: 10 * time.Second}
+		}
+		if slack.RetryAttempts == 0 {
+			slack.RetryAttempts = 3
+		}
+		if slack.APIURL == "" {
+			slack.APIURL = "https://slack.com/api"
+		}
+		ch.Slack = &slack
+	}
+	if ch.Teams != nil {
+		teams := *ch.Teams
+		if teams.Timeout.Duration == 0 {
+			teams.Timeout = Duration{Duration: 10 * time.Second}
+		}
+		if teams.RetryAttempts == 0 {
+			teams.RetryAttempts = 3
+		}
+		ch.Teams = &teams
+	}
+	if ch.SMTP != nil {
+		smtp := *ch.SMTP
+		if smtp.Port == 0 {
+			smtp.Port = 587
+		}
+		if smtp.TLS == "" {
+			smtp.TLS = "starttls"
+		}
+		if smtp.Timeout.Duration == 0 {
+			smtp.Timeout = Duration{Duration: 30 * time.Second}
+		}
+		if smtp.RetryAttempts == 0 {
+			smtp.RetryAttempts = 3
+		}
+		ch.SMTP = &smtp
+	}
+	if ch.Webhook != nil {
+		webhook := *ch.Webhook
+		if webhook.Timeout.Duration == 0 {
+			webhook.Timeout = Duration{Duration: 10 * time.Second}
+		}
+		if webhook.RetryAttempts == 0 {
+			webhook.RetryAttempts = 3
+		}
+		ch.Webhook = &webhook
+	}
+	if ch.PagerDuty != nil {
+		pagerDuty := *ch.PagerDuty
+		if pagerDuty.EventsURL == "" {
+			pagerDuty.EventsURL = "https://events.pagerduty.com/v2/enqueue"
+		}
+		if pagerDuty.Severity == "" {
+			pagerDuty.Severity = "error"
+		}
+		if pagerDuty.Timeout.Duration == 0 {
+			pagerDuty.Timeout = Duration{Duration: 10 * time.Second}
+		}
+		if pagerDuty.RetryAttempts == 0 {
+			pagerDuty.RetryAttempts = 3
+		}
+		ch.PagerDuty = &pagerDuty
+	}
+	return ch
+}
+
+// validateChannels checks that every channel has a unique name and the
+// settings block required by its type
+func (c *Config) validateChannels() error {
+	names := make(map[string]bool)
+	for i, ch := range c.Channels {
+		if ch.Name == "" {
+			return fmt.Errorf("channels[%d]: name is required", i)
+		}
+		if names[ch.Name] {
+			return fmt.Errorf("channels[%d]: duplicate channel name %q", i, ch.Name)
+		}
+		names[ch.Name] = true
+
+		switch ch.Type {
+		case ChannelTypeSlack:
+			if ch.Slack == nil || !ch.Slack.IsConfigured() {
+				return fmt.Errorf("channel %q: slack.webhook_url or slack.bot_token is required", ch.Name)
+			}
+			if ch.Slack.BotToken != "" && ch.Slack.Channel == "" {
+				return fmt.Errorf("channel %q: slack.channel is required with slack.bot_token", ch.Name)
+			}
+		case ChannelTypeTeams:
+			if ch.Teams == nil || ch.Teams.WebhookURL == "" {
+				return fmt.Errorf("channel %q: teams.webhook_url is required", ch.Name)
+			}
+		case ChannelTypeSMTP:
+			if ch.SMTP == nil || ch.SMTP.Host == "" {
+				return fmt.Errorf("channel %q: smtp.host is required", ch.Name)
+			}
+			if ch.SMTP.From == "" {
+				return fmt.Errorf("channel %q: smtp.from is required", ch.Name)
+			}
+			if len(ch.SMTP.To) == 0 && len(ch.SMTP.RecipientsByType) == 0 {
+				return fmt.Errorf("channel %q: smtp.to or smtp.recipients_by_type is required", ch.Name)
+			}
+			switch ch.SMTP.TLS {
+			case "", "starttls", "implicit", "none":
+			default:
+				return fmt.Errorf("channel %q: smtp.tls must be starttls, implicit or none", ch.Name)
+			}
+		case ChannelTypeWebhook:
+			if ch.Webhook == nil || ch.Webhook.URL == "" {
+				return fmt.Errorf("channel %q: webhook.url is required", ch.Name)
+			}
+			if ch.Webhook.Secret == "" && !ch.Webhook.Unsigned {
+				return fmt.Errorf("channel %q: webhook.secret is required, or set webhook.unsigned to send without a signature", ch.Name)
+			}
+			if ch.Webhook.Secret != "" && ch.Webhook.Unsigned {
+				return fmt.Errorf("channel %q: webhook.secret cannot be combined with webhook.unsigned", ch.Name)
+			}
+		case ChannelTypePagerDuty:
+			if ch.PagerDuty == nil || ch.PagerDuty.RoutingKey == "" {
+				return fmt.Errorf("channel %q: pagerduty.routing_key is required", ch.Name)
+			}
+			switch ch.PagerDuty.Severity {
+			case "", "critical", "error", "warning", "info":
+			default:
+				return fmt.Errorf("channel %q: pagerduty.severity must be critical, error, warning or info", ch.Name)
+			}
+		default:
+			return fmt.Errorf("channel %q: unknown type %q", ch.Name, ch.Type)
+		}
+	}
+
+	return nil
+}
+
+// channelNames returns the set of configured channel names
+func (c *Config) channelNames() map[string]bool {
+	names := make(map[string]bool)
+	for _, ch := range c.ChannelConfigs() {
+		names[ch.Name] = true
 	}
-	if c.BusinessHours.EndHour < 0 || c.BusinessHours.EndHour > 23 {
-		return fmt.Errorf("--business-hours-end must be 0-23")
+	return names
+}
+
+// validateRouting checks that every route refers to a configured channel
+func (c *Config) validateRouting() error {
+	names := c.channelNames()
+
+	for _, name := range c.Routing.Default {
+		if !names[name] {
+			return fmt.Errorf("routing.default: unknown channel %q", name)
+		}
+	}
+	for mailboxID, channels := range c.Routing.Mailboxes {
+		for _, name := range channels {
+			if !names[name] {
+				return fmt.Errorf("routing.mailboxes[%d]: unknown channel %q", mailboxID, name)
+			}
+		}
 	}
-	if c.BusinessHours.StartHour >= c.BusinessHours.EndHour {
-		return fmt.Errorf("--business-hours-start must be before --business-hours-end")
+	for tag, channels := range c.Routing.Tags {
+		for _, name := range channels {
+			if !names[name] {
+				return fmt.Errorf("routing.tags[%q]: unknown channel %q", tag, name)
+			}
+		}
 	}
 
 	return nil
 }
 
+// validateFilters checks that mailbox and user IDs are positive and not both
+// included and excluded
+func (c *Config) validateFilters() error {
+	lists := []struct {
+		name string
+		ids  []int
+	}{
+		{"include_mailboxes", c.Filters.IncludeMailboxes},
+		{"exclude_mailboxes", c.Filters.ExcludeMailboxes},
+		{"include_users", c.Filters.IncludeUsers},
+		{"exclude_users", c.Filters.ExcludeUsers},
+	}
+	for _, list := range lists {
+		for _, id := range list.ids {
+			if id <= 0 {
+				return fmt.Errorf("filters.%s: invalid ID %d", list.name, id)
+			}
+		}
+	}
+
+	for _, id := range c.Filters.IncludeMailboxes {
+		if containsInt(c.Filters.ExcludeMailboxes, id) {
+			return fmt.Errorf("filters: mailbox %d is both included and excluded", id)
+		}
+	}
+	for _, id := range c.Filters.IncludeUsers {
+		if containsInt(c.Filters.ExcludeUsers, id) {
+			return fmt.Errorf("filters: user %d is both included and excluded", id)
+		}
+	}
+
+	return nil
+}
+
+func containsInt(ids []int, id int) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) validateDigest() error {
+	switch c.Digest.Schedule {
+	case "", DigestDaily, DigestWeekly:
+	default:
+		return fmt.Errorf("--digest-schedule must be daily or weekly")
+	}
+	if c.Digest.After.Duration < 0 {
+		return fmt.Errorf("--digest-after must not be negative")
+	}
+
+	names := c.channelNames()
+	for _, name := range c.Digest.Channels {
+		if !names[name] {
+			return fmt.Errorf("digest.channels: unknown channel %q", name)
+		}
+	}
+
+	return nil
+}
+
+// validateEscalation checks the escalation ladder
+func (c *Config) validateEscalation() error {
+	names := c.channelNames()
+
+	var previous time.Duration
+	for i, tier := range c.Escalation {
+		if tier.After.Duration <= previous {
+			return fmt.Errorf("escalation[%d]: after must be greater than the previous tier", i)
+		}
+		previous = tier.After.Duration
+
+		for _, name := range tier.Channels {
+			if !names[name] {
+				return fmt.Errorf("escalation[%d]: unknown channel %q", i, name)
+			}
+		}
+		switch tier.Tone {
+		case "", "normal", "warning", "urgent":
+		default:
+			return fmt.Errorf("escalation[%d]: tone must be normal, warning or urgent", i)
+		}
+	}
+
+	return nil
+}
+
+// validateRules checks rule actions and their targets. Expressions are
+// compiled when the notifier starts.
+func (c *Config) validateRules() error {
+	names := c.channelNames()
+
+	for i, rule := range c.Rules {
+		if strings.TrimSpace(rule.When) == "" {
+			return fmt.Errorf("rules[%d]: when is required", i)
+		}
+
+		switch rule.Action {
+		case "notify":
+			if len(rule.Channels) == 0 {
+				return fmt.Errorf("rules[%d]: channels is required for the notify action", i)
+			}
+			for _, name := range rule.Channels {
+				if !names[name] {
+					return fmt.Errorf("rules[%d]: unknown channel %q", i, name)
+				}
+			}
+		case "suppress":
+		case "escalate":
+			if rule.Tier < 1 || rule.Tier > len(c.Escalation) {
+				return fmt.Errorf("rules[%d]: tier must refer to one of the %d escalation tiers", i, len(c.Escalation))
+			}
+		default:
+			return fmt.Errorf("rules[%d]: action must be notify, suppress or escalate", i)
+		}
+	}
+
+	return nil
+}
+
+// OpenThresholdFor returns the open ticket threshold for a mailbox
+func (c *Config) OpenThresholdFor(mailboxID int) time.Duration {
+	if d := c.Mailboxes[mailboxID].OpenThreshold.Duration; d > 0 {
+		return d
+	}
+	return c.OpenThreshold.Duration
+}
+
+// PendingThresholdFor returns the pending ticket threshold for a mailbox
+func (c *Config) PendingThresholdFor(mailboxID int) time.Duration {
+	if d := c.Mailboxes[mailboxID].PendingThreshold.Duration; d > 0 {
+		return d
+	}
+	return c.PendingThreshold.Duration
+}
+
+// UnassignedThresholdFor returns the unassigned conversation threshold for a mailbox
+func (c *Config) UnassignedThresholdFor(mailboxID int) time.Duration {
+	if d := c.Mailboxes[mailboxID].UnassignedThreshold.Duration; d > 0 {
+		return d
+	}
+	return c.UnassignedThreshold.Duration
+}
+
+// ReturnedThresholdFor returns the threshold for unanswered customer replies
+// to pending tickets in a mailbox
+func (c *Config) ReturnedThresholdFor(mailboxID int) time.Duration {
+	if d := c.Mailboxes[mailboxID].ReturnedThreshold.Duration; d > 0 {
+		return d
+	}
+	return c.ReturnedThreshold.Duration
+}
+
+// CooldownFor returns the cooldown between notifications for a mailbox
+func (c *Config) CooldownFor(mailboxID int) time.Duration {
+	if d := c.Mailboxes[mailboxID].CooldownPeriod.Duration; d > 0 {
+		return d
+	}
+	return c.CooldownPeriod.Duration
+}
+
 // validateDSN performs basic validation on the MySQL DSN format
 func (c *Config) validateDSN() error {
 	dsn := c.FreeScout.DSN
@@ -292,3 +977,20 @@ func parseWorkDays(s string) []time.Weekday {
 
 	return days
 }
+
+// parseIDs parses a comma-separated list of IDs
+func parseIDs(s string) ([]int, error) {
+	var ids []int
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		id, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID %q", p)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TimeRange is a period of a day, written as "08:30-12:00" in JSON. A range
+// that ends at or before its start, such as "22:00-06:00", runs past
+// midnight into the next day.
+type TimeRange struct {
+	Start int // Minutes since midnight
+	End   int // Minutes since midnight
+}
+
+// ParseTimeRange parses a range such as "08:30-12:00"
+func ParseTimeRange(s string) (TimeRange, error) {
+	parts := strings.SplitN(s, "-", 2)
+	if len(parts) != 2 {
+		return TimeRange{}, fmt.Errorf("invalid time range %q, expected HH:MM-HH:MM", s)
+	}
+
+	var r TimeRange
+	var err error
+	if r.Start, err = parseClock(parts[0]); err != nil {
+		return TimeRange{}, err
+	}
+	if r.End, err = parseClock(parts[1]); err != nil {
+		return TimeRange{}, err
+	}
+	if r.Start == 24*60 {
+		return TimeRange{}, fmt.Errorf("invalid time range %q, start must be before 24:00", s)
+	}
+	return r, nil
+}
+
+// parseClock parses a time of day such as "08:30" or "24:00" into minutes
+// since midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		if strings.TrimSpace(s) == "24:00" {
+			return 24 * 60, nil
+		}
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Overnight reports whether the range ends on the next day
+func (r TimeRange) Overnight() bool {
+	return r.End <= r.Start
+}
+
+// Contains reports whether the minute of the day the range starts on falls
+// within it
+func (r TimeRange) Contains(minute int) bool {
+	return minute >= r.Start && (r.Overnight() || minute < r.End)
+}
+
+// ContainsNextDay reports whether the minute of the following day falls
+// within the part of an overnight range after midnight
+func (r TimeRange) ContainsNextDay(minute int) bool {
+	return r.Overnight() && minute < r.End
+}
+
+// String returns the range as "08:30-12:00"
+func (r TimeRange) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", r.Start/60, r.Start%60, r.End/60, r.End%60)
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (r *TimeRange) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("invalid time range %s, expected \"HH:MM-HH:MM\"", b)
+	}
+	parsed, err := ParseTimeRange(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler interface
+func (r TimeRange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// weekdays maps the names accepted as schedule keys to weekdays
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// ParseWeekday parses a weekday name such as "monday" or "mon"
+func ParseWeekday(name string) (time.Weekday, bool) {
+	day, ok := weekdays[strings.ToLower(name)]
+	return day, ok
+}
+
+// WeeklySchedule returns the business hours of each weekday, sorted by start.
+// Without a schedule, StartHour, EndHour and WorkDays give every work day a
+// single range, which runs past midnight when EndHour is not after StartHour.
+func (b BusinessHoursConfig) WeeklySchedule() (map[time.Weekday][]TimeRange, error) {
+	schedule := make(map[time.Weekday][]TimeRange)
+
+	if len(b.Schedule) == 0 {
+		for _, day := range b.WorkDays {
+			schedule[day] = []TimeRange{{Start: b.StartHour * 60, End: b.EndHour * 60}}
+		}
+		return schedule, nil
+	}
+
+	for name, ranges := range b.Schedule {
+		day, ok := ParseWeekday(name)
+		if !ok {
+			return nil, fmt.Errorf("business_hours.schedule: unknown weekday %q", name)
+		}
+		if _, ok := schedule[day]; ok {
+			return nil, fmt.Errorf("business_hours.schedule: %s is listed twice", day)
+		}
+
+		sorted := append([]TimeRange(nil), ranges...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+		for i := 1; i < len(sorted); i++ {
+			if sorted[i].Start < sorted[i-1].End || sorted[i-1].Overnight() {
+				return nil, fmt.Errorf("business_hours.schedule: %s ranges %s and %s overlap", day, sorted[i-1], sorted[i])
+			}
+		}
+		schedule[day] = sorted
+	}
+
+	// An overnight range must end before the next day opens
+	for day, ranges := range schedule {
+		next := schedule[(day+1)%7]
+		if len(ranges) == 0 || len(next) == 0 {
+			continue
+		}
+		if last := ranges[len(ranges)-1]; last.Overnight() && last.End > next[0].Start {
+			return nil, fmt.Errorf("business_hours.schedule: %s range %s overlaps %s", day, last, (day+1)%7)
+		}
+	}
+
+	return schedule, nil
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseTimeRange(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    TimeRange
+		wantErr string
+	}{
+		{"08:30-12:00", TimeRange{Start: 8*60 + 30, End: 12 * 60}, ""},
+		{" 08:30 - 12:00 ", TimeRange{Start: 8*60 + 30, End: 12 * 60}, ""},
+		{"22:00-06:00", TimeRange{Start: 22 * 60, End: 6 * 60}, ""},
+		{"00:00-24:00", TimeRange{Start: 0, End: 24 * 60}, ""},
+		{"24:00-08:00", TimeRange{}, "start must be before 24:00"},
+		{"09:60-17:00", TimeRange{}, `invalid time "09:60"`},
+		{"09:00-24:30", TimeRange{}, `invalid time "24:30"`},
+		{"9-17", TimeRange{}, `invalid time "9"`},
+		{"09:00", TimeRange{}, "expected HH:MM-HH:MM"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseTimeRange(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseTimeRange(%q) error = %v, want it to contain %q", tt.in, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTimeRange(%q) error = %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseTimeRange(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeeklyScheduleErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     string
+		wantErr string
+	}{
+		{"unknown weekday", `{"schedule": {"someday": ["09:00-17:00"]}}`, `unknown weekday "someday"`},
+		{"weekday listed twice", `{"schedule": {"mon": ["09:00-12:00"], "monday": ["13:00-17:00"]}}`, "Monday is listed twice"},
+		{"overlapping ranges", `{"schedule": {"mon": ["09:00-12:00", "11:00-17:00"]}}`, "Monday ranges 09:00-12:00 and 11:00-17:00 overlap"},
+		{"range after an overnight range", `{"schedule": {"mon": ["22:00-02:00", "23:00-23:30"]}}`, "Monday ranges 22:00-02:00 and 23:00-23:30 overlap"},
+		{"overnight into the next day", `{"schedule": {"mon": ["22:00-09:00"], "tue": ["08:00-17:00"]}}`, "Monday range 22:00-09:00 overlaps Tuesday"},
+		{"overnight from Saturday into Sunday", `{"schedule": {"sat": ["20:00-06:00"], "sun": ["05:00-12:00"]}}`, "Saturday range 20:00-06:00 overlaps Sunday"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg BusinessHoursConfig
+			if err := json.Unmarshal([]byte(tt.cfg), &cfg); err != nil {
+				t.Fatalf("unmarshal config: %v", err)
+			}
+
+			_, err := cfg.WeeklySchedule()
+			if err == nil {
+				t.Fatalf("WeeklySchedule() = nil error, want error containing %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("WeeklySchedule() error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWeeklyScheduleBackToBack(t *testing.T) {
+	// An overnight range may end exactly when the next day opens
+	var cfg BusinessHoursConfig
+	if err := json.Unmarshal([]byte(`{"schedule": {"mon": ["22:00-08:00"], "tue": ["08:00-17:00"]}}`), &cfg); err != nil {
+		t.Fatalf("unmarshal config: %v", err)
+	}
+	if _, err := cfg.WeeklySchedule(); err != nil {
+		t.Errorf("WeeklySchedule() error = %v, want nil", err)
+	}
+}
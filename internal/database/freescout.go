@@ -2,10 +2,13 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/voicetel/freescout-notifier/internal/config"
 	"github.com/voicetel/freescout-notifier/internal/models"
 )
@@ -31,31 +34,213 @@ func ConnectFreeScout(cfg config.FreeScoutConfig) (*sql.DB, error) {
 	return db, nil
 }
 
-func GetOpenTicketsNeedingAttention(db *sql.DB, threshold config.Duration) ([]models.Ticket, error) {
-	query := `
+// TicketQuery holds the thresholds used to select tickets needing attention
+type TicketQuery struct {
+	Threshold         time.Duration         // Default time since the last reply
+	MailboxThresholds map[int]time.Duration // Per-mailbox overrides of Threshold
+	VIP               VIPCustomers          // Customers whose tickets use VIPThreshold
+	VIPThreshold      time.Duration         // Used instead of the mailbox threshold when shorter, 0 disables
+	Filter            Filter                // Mailboxes and assignees to look at
+}
+
+// Filter limits the conversations that are queried. Empty include lists
+// match everything, and the user lists never exclude unassigned
+// conversations.
+type Filter struct {
+	IncludeMailboxes []int
+	ExcludeMailboxes []int
+	IncludeUsers     []int
+	ExcludeUsers     []int
+}
+
+// condition returns SQL conditions, each starting with AND, that apply the
+// filter to the conversations aliased c
+func (f Filter) condition() (string, []interface{}) {
+	sql, args := f.mailboxCondition("c.mailbox_id")
+	for _, c := range []struct {
+		format string
+		ids    []int
+	}{
+		{"(c.user_id IS NULL OR c.user_id IN (%s))", f.IncludeUsers},
+		{"(c.user_id IS NULL OR c.user_id NOT IN (%s))", f.ExcludeUsers},
+	} {
+		cond, condArgs := idCondition(c.format, c.ids)
+		sql += cond
+		args = append(args, condArgs...)
+	}
+	return sql, args
+}
+
+// Matches reports whether a ticket passes the filter, the same way condition
+// does in the FreeScout queries
+func (f Filter) Matches(ticket models.Ticket) bool {
+	if len(f.IncludeMailboxes) > 0 && !containsID(f.IncludeMailboxes, ticket.MailboxID) {
+		return false
+	}
+	if containsID(f.ExcludeMailboxes, ticket.MailboxID) {
+		return false
+	}
+
+	// Unassigned conversations are never filtered by user
+	if ticket.AssignedUserID == nil {
+		return true
+	}
+	if len(f.IncludeUsers) > 0 && !containsID(f.IncludeUsers, *ticket.AssignedUserID) {
+		return false
+	}
+	return !containsID(f.ExcludeUsers, *ticket.AssignedUserID)
+}
+
+func containsID(ids []int, id int) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// mailboxCondition returns SQL conditions, each starting with AND, that
+// apply the mailbox lists to column
+func (f Filter) mailboxCondition(column string) (string, []interface{}) {
+	include, args := idCondition(column+" IN (%s)", f.IncludeMailboxes)
+	exclude, excludeArgs := idCondition(column+" NOT IN (%s)", f.ExcludeMailboxes)
+	return include + exclude, append(args, excludeArgs...)
+}
+
+// idCondition formats a condition on a list of IDs, or returns "" when the
+// list is empty
+func idCondition(format string, ids []int) (string, []interface{}) {
+	if len(ids) == 0 {
+		return "", nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return " AND " + fmt.Sprintf(format, placeholders(len(ids))), args
+}
+
+// VIPCustomers identifies the tickets of VIP customers. Emails and domains
+// are lowercase.
+type VIPCustomers struct {
+	Emails      []string
+	Domains     []string
+	CustomerIDs []int
+}
+
+// condition returns an SQL condition matching VIP tickets, or "" when the
+// list is empty
+func (v VIPCustomers) condition() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if len(v.CustomerIDs) > 0 {
+		conditions = append(conditions, fmt.Sprintf("c.customer_id IN (%s)", placeholders(len(v.CustomerIDs))))
+		for _, id := range v.CustomerIDs {
+			args = append(args, id)
+		}
+	}
+	if len(v.Emails) > 0 {
+		conditions = append(conditions, fmt.Sprintf("LOWER(c.customer_email) IN (%s)", placeholders(len(v.Emails))))
+		for _, email := range v.Emails {
+			args = append(args, email)
+		}
+	}
+	if len(v.Domains) > 0 {
+		conditions = append(conditions, fmt.Sprintf("SUBSTRING_INDEX(LOWER(c.customer_email), '@', -1) IN (%s)", placeholders(len(v.Domains))))
+		for _, domain := range v.Domains {
+			args = append(args, domain)
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+// placeholders returns n comma-separated query placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// thresholdMinutes returns an SQL expression for the threshold in minutes of
+// a conversation, together with its arguments. VIP tickets use the VIP
+// threshold when it is shorter than the threshold of their mailbox.
+func (q TicketQuery) thresholdMinutes() (string, []interface{}) {
+	threshold, args := q.mailboxThresholdMinutes()
+
+	vip, vipArgs := q.VIP.condition()
+	if vip == "" || q.VIPThreshold <= 0 {
+		return threshold, args
+	}
+
+	var caseArgs []interface{}
+	caseArgs = append(caseArgs, vipArgs...)
+	caseArgs = append(caseArgs, int(q.VIPThreshold.Minutes()))
+	caseArgs = append(caseArgs, args...)
+	caseArgs = append(caseArgs, args...)
+	return fmt.Sprintf("CASE WHEN %s THEN LEAST(?, %s) ELSE %s END", vip, threshold, threshold), caseArgs
+}
+
+// mailboxThresholdMinutes returns an SQL expression for the threshold in
+// minutes of a conversation's mailbox, together with its arguments
+func (q TicketQuery) mailboxThresholdMinutes() (string, []interface{}) {
+	var expr strings.Builder
+	var args []interface{}
+
+	mailboxIDs := make([]int, 0, len(q.MailboxThresholds))
+	for id := range q.MailboxThresholds {
+		mailboxIDs = append(mailboxIDs, id)
+	}
+	sort.Ints(mailboxIDs)
+
+	for _, id := range mailboxIDs {
+		expr.WriteString(" WHEN c.mailbox_id = ? THEN ?")
+		args = append(args, id, int(q.MailboxThresholds[id].Minutes()))
+	}
+
+	if len(args) == 0 {
+		return "?", []interface{}{int(q.Threshold.Minutes())}
+	}
+	args = append(args, int(q.Threshold.Minutes()))
+
+	return "CASE" + expr.String() + " ELSE ? END", args
+}
+
+func GetOpenTicketsNeedingAttention(db *sql.DB, q TicketQuery) ([]models.Ticket, error) {
+	threshold, args := q.thresholdMinutes()
+	filter, filterArgs := q.Filter.condition()
+	args = append(args, filterArgs...)
+	query := fmt.Sprintf(`
 		SELECT DISTINCT
 			c.id AS ticket_id,
 			c.number AS ticket_number,
 			c.subject,
 			c.customer_email,
+			COALESCE(c.customer_id, 0) AS customer_id,
 			CONCAT(COALESCE(cust.first_name, ''), ' ', COALESCE(cust.last_name, '')) AS customer_name,
 			c.user_id AS assigned_user_id,
 			CONCAT(COALESCE(u.first_name, ''), ' ', COALESCE(u.last_name, '')) AS assigned_user_name,
 			c.last_reply_at,
 			TIMESTAMPDIFF(MINUTE, c.last_reply_at, NOW()) AS minutes_since_reply,
-			c.mailbox_id
+			c.mailbox_id,
+			COALESCE(m.name, '') AS mailbox_name
 		FROM conversations c
 		LEFT JOIN customers cust ON c.customer_id = cust.id
 		LEFT JOIN users u ON c.user_id = u.id
+		LEFT JOIN mailboxes m ON c.mailbox_id = m.id
 		WHERE c.status = 1  -- Active/Open status
 			AND c.state != 3  -- Exclude deleted/trashed tickets
 			AND c.last_reply_from = 1  -- Last reply was from customer
-			AND c.last_reply_at < DATE_SUB(NOW(), INTERVAL ? MINUTE)
+			AND c.last_reply_at < DATE_SUB(NOW(), INTERVAL (%s) MINUTE)
 			AND c.last_reply_at > DATE_SUB(NOW(), INTERVAL 30 DAY)  -- Limit to recent tickets
+			%s
 		ORDER BY c.last_reply_at ASC
-	`
+	`, threshold, filter)
 
-	rows, err := db.Query(query, int(threshold.Duration.Minutes()))
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -64,31 +249,38 @@ func GetOpenTicketsNeedingAttention(db *sql.DB, threshold config.Duration) ([]mo
 	return scanTickets(rows, models.OpenNoAgentResponse)
 }
 
-func GetPendingTicketsNeedingAttention(db *sql.DB, threshold config.Duration) ([]models.Ticket, error) {
-	query := `
+func GetPendingTicketsNeedingAttention(db *sql.DB, q TicketQuery) ([]models.Ticket, error) {
+	threshold, args := q.thresholdMinutes()
+	filter, filterArgs := q.Filter.condition()
+	args = append(args, filterArgs...)
+	query := fmt.Sprintf(`
 		SELECT DISTINCT
 			c.id AS ticket_id,
 			c.number AS ticket_number,
 			c.subject,
 			c.customer_email,
+			COALESCE(c.customer_id, 0) AS customer_id,
 			CONCAT(COALESCE(cust.first_name, ''), ' ', COALESCE(cust.last_name, '')) AS customer_name,
 			c.user_id AS assigned_user_id,
 			CONCAT(COALESCE(u.first_name, ''), ' ', COALESCE(u.last_name, '')) AS assigned_user_name,
 			c.last_reply_at,
 			TIMESTAMPDIFF(MINUTE, c.last_reply_at, NOW()) AS minutes_since_reply,
-			c.mailbox_id
+			c.mailbox_id,
+			COALESCE(m.name, '') AS mailbox_name
 		FROM conversations c
 		LEFT JOIN customers cust ON c.customer_id = cust.id
 		LEFT JOIN users u ON c.user_id = u.id
+		LEFT JOIN mailboxes m ON c.mailbox_id = m.id
 		WHERE c.status = 2  -- Pending status
 			AND c.state != 3  -- Exclude deleted/trashed tickets
 			AND c.last_reply_from = 2  -- Last reply was from user/agent
-			AND c.last_reply_at < DATE_SUB(NOW(), INTERVAL ? MINUTE)
+			AND c.last_reply_at < DATE_SUB(NOW(), INTERVAL (%s) MINUTE)
 			AND c.last_reply_at > DATE_SUB(NOW(), INTERVAL 60 DAY)  -- Limit to recent tickets
+			%s
 		ORDER BY c.last_reply_at ASC
-	`
+	`, threshold, filter)
 
-	rows, err := db.Query(query, int(threshold.Duration.Minutes()))
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -97,6 +289,207 @@ func GetPendingTicketsNeedingAttention(db *sql.DB, threshold config.Duration) ([
 	return scanTickets(rows, models.PendingNoCustomerResponse)
 }
 
+// LoadTags fills in the tags of each ticket from the FreeScout Tags module.
+// Tickets are left without tags when the module is not installed.
+func LoadTags(db *sql.DB, tickets []models.Ticket) error {
+	if len(tickets) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(tickets))
+	for i, t := range tickets {
+		args[i] = t.ID
+	}
+
+	query := fmt.Sprintf(`
+		SELECT ct.conversation_id, t.name
+		FROM conversation_tag ct
+		JOIN tags t ON t.id = ct.tag_id
+		WHERE ct.conversation_id IN (%s)
+		ORDER BY t.name
+	`, placeholders(len(args)))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1146 { // Table doesn't exist
+			return nil
+		}
+		return fmt.Errorf("tag query failed: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make(map[int][]string)
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return fmt.Errorf("scan failed: %w", err)
+		}
+		tags[id] = append(tags[id], name)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range tickets {
+		tickets[i].Tags = tags[tickets[i].ID]
+	}
+	return nil
+}
+
+// GetUnassignedTicketsNeedingAttention returns active conversations that
+// have had no assignee for longer than the threshold, regardless of who
+// replied last. The waiting time is measured from the conversation's creation.
+func GetUnassignedTicketsNeedingAttention(db *sql.DB, q TicketQuery) ([]models.Ticket, error) {
+	threshold, args := q.thresholdMinutes()
+	filter, filterArgs := q.Filter.condition()
+	args = append(args, filterArgs...)
+	query := fmt.Sprintf(`
+		SELECT DISTINCT
+			c.id AS ticket_id,
+			c.number AS ticket_number,
+			c.subject,
+			c.customer_email,
+			COALESCE(c.customer_id, 0) AS customer_id,
+			CONCAT(COALESCE(cust.first_name, ''), ' ', COALESCE(cust.last_name, '')) AS customer_name,
+			c.user_id AS assigned_user_id,
+			'' AS assigned_user_name,
+			COALESCE(c.last_reply_at, c.created_at) AS last_reply_at,
+			TIMESTAMPDIFF(MINUTE, c.created_at, NOW()) AS minutes_since_reply,
+			c.mailbox_id,
+			COALESCE(m.name, '') AS mailbox_name
+		FROM conversations c
+		LEFT JOIN customers cust ON c.customer_id = cust.id
+		LEFT JOIN mailboxes m ON c.mailbox_id = m.id
+		WHERE c.status = 1  -- Active/Open status
+			AND c.state != 3  -- Exclude deleted/trashed tickets
+			AND c.user_id IS NULL  -- Nobody assigned
+			AND c.created_at < DATE_SUB(NOW(), INTERVAL (%s) MINUTE)
+			AND c.created_at > DATE_SUB(NOW(), INTERVAL 30 DAY)  -- Limit to recent tickets
+			%s
+		ORDER BY c.created_at ASC
+	`, threshold, filter)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	return scanTickets(rows, models.UnassignedConversation)
+}
+
+// GetPendingConversationIDs returns the IDs of all recent pending
+// conversations matching the filter
+func GetPendingConversationIDs(db *sql.DB, f Filter) ([]int, error) {
+	filter, args := f.condition()
+	query := fmt.Sprintf(`
+		SELECT c.id
+		FROM conversations c
+		WHERE c.status = 2  -- Pending status
+			AND c.state != 3  -- Exclude deleted/trashed tickets
+			AND c.last_reply_at > DATE_SUB(NOW(), INTERVAL 60 DAY)  -- Limit to recent tickets
+			%s
+	`, filter)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// GetReturnedTickets returns the conversations among ids that match the
+// filter and are active again with the last reply from the customer. The
+// waiting time is measured from that reply.
+func GetReturnedTickets(db *sql.DB, ids []int, f Filter) ([]models.Ticket, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	filter, filterArgs := f.condition()
+	args = append(args, filterArgs...)
+
+	query := fmt.Sprintf(`
+		SELECT DISTINCT
+			c.id AS ticket_id,
+			c.number AS ticket_number,
+			c.subject,
+			c.customer_email,
+			COALESCE(c.customer_id, 0) AS customer_id,
+			CONCAT(COALESCE(cust.first_name, ''), ' ', COALESCE(cust.last_name, '')) AS customer_name,
+			c.user_id AS assigned_user_id,
+			CONCAT(COALESCE(u.first_name, ''), ' ', COALESCE(u.last_name, '')) AS assigned_user_name,
+			c.last_reply_at,
+			TIMESTAMPDIFF(MINUTE, c.last_reply_at, NOW()) AS minutes_since_reply,
+			c.mailbox_id,
+			COALESCE(m.name, '') AS mailbox_name
+		FROM conversations c
+		LEFT JOIN customers cust ON c.customer_id = cust.id
+		LEFT JOIN users u ON c.user_id = u.id
+		LEFT JOIN mailboxes m ON c.mailbox_id = m.id
+		WHERE c.id IN (%s)
+			AND c.status = 1  -- Active/Open status
+			AND c.state != 3  -- Exclude deleted/trashed tickets
+			AND c.last_reply_from = 1  -- Last reply was from customer
+			%s
+		ORDER BY c.last_reply_at ASC
+	`, placeholders(len(ids)), filter)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	return scanTickets(rows, models.ReturnedFromPending)
+}
+
+// GetWatchedMailboxes returns the mailboxes allowed by the filter's mailbox
+// lists
+func GetWatchedMailboxes(db *sql.DB, f Filter) ([]models.Mailbox, error) {
+	filter, args := f.mailboxCondition("m.id")
+	query := fmt.Sprintf(`
+		SELECT m.id, m.name
+		FROM mailboxes m
+		WHERE 1 = 1 %s
+		ORDER BY m.id
+	`, filter)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var mailboxes []models.Mailbox
+	for rows.Next() {
+		var m models.Mailbox
+		if err := rows.Scan(&m.ID, &m.Name); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		mailboxes = append(mailboxes, m)
+	}
+
+	return mailboxes, rows.Err()
+}
+
 func scanTickets(rows *sql.Rows, notificationType models.NotificationType) ([]models.Ticket, error) {
 	var tickets []models.Ticket
 
@@ -109,12 +502,14 @@ func scanTickets(rows *sql.Rows, notificationType models.NotificationType) ([]mo
 			&t.Number,
 			&t.Subject,
 			&t.CustomerEmail,
+			&t.CustomerID,
 			&t.CustomerName,
 			&t.AssignedUserID,
 			&t.AssignedUserName,
 			&t.LastReplyAt,
 			&t.MinutesSinceReply,
 			&t.MailboxID,
+			&t.MailboxName,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("scan failed: %w", err)
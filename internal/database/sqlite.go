@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/voicetel/freescout-notifier/internal/models"
 )
 
 type DB struct {
@@ -60,6 +62,8 @@ func InitSchema(db *DB) error {
 		assigned_user TEXT,
 		minutes_waiting INTEGER,
 		threshold_minutes INTEGER,
+		escalation_tier INTEGER NOT NULL DEFAULT 0,
+		last_reply_at TIMESTAMP DEFAULT NULL,
 		ticket_data TEXT,
 		UNIQUE(ticket_id, notification_type)
 	);
@@ -72,15 +76,91 @@ func InitSchema(db *DB) error {
 		event_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		notifications_sent INTEGER DEFAULT 0
 	);
+
+	CREATE TABLE IF NOT EXISTS slack_messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ticket_id INTEGER NOT NULL,
+		notification_type TEXT NOT NULL,
+		channel_name TEXT NOT NULL,
+		slack_channel TEXT NOT NULL,
+		message_ts TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(ticket_id, notification_type, channel_name)
+	);
+
+	CREATE TABLE IF NOT EXISTS ticket_actions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ticket_id INTEGER NOT NULL,
+		notification_type TEXT NOT NULL,
+		action TEXT NOT NULL,
+		slack_user_id TEXT,
+		slack_user_name TEXT,
+		last_reply_unix INTEGER,
+		until_unix INTEGER,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_ticket_actions ON ticket_actions(ticket_id);
+
+	CREATE TABLE IF NOT EXISTS pending_conversations (
+		ticket_id INTEGER PRIMARY KEY,
+		last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
 	`
 
 	if _, err := db.Exec(schema); err != nil {
 		return fmt.Errorf("failed to create schema: %w", err)
 	}
 
+	for _, m := range migrations {
+		if err := db.ensureColumn(m.table, m.column, m.definition); err != nil {
+			return fmt.Errorf("failed to migrate schema: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// migrations lists columns added after their table was first released, so
+// --init-db can upgrade existing databases
+var migrations = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"notifications", "escalation_tier", "INTEGER NOT NULL DEFAULT 0"},
+	{"notifications", "last_reply_at", "TIMESTAMP DEFAULT NULL"},
+	{"slack_messages", "batched", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// ensureColumn adds a column to a table unless it already exists
+func (db *DB) ensureColumn(table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
 // GetNotificationStats returns statistics about notifications
 func (db *DB) GetNotificationStats() (map[string]interface{}, error) {
 	stats := make(map[string]interface{})
@@ -116,11 +196,11 @@ func (db *DB) GetNotificationStats() (map[string]interface{}, error) {
 	}
 	stats["by_status"] = statusCounts
 
-	// Notifications by type
+	// Notifications by type, broken down by status
 	typeQuery := `
-		SELECT notification_type, COUNT(*)
+		SELECT notification_type, notification_status, COUNT(*)
 		FROM notifications
-		GROUP BY notification_type
+		GROUP BY notification_type, notification_status
 	`
 	rows, err = db.Query(typeQuery)
 	if err != nil {
@@ -129,15 +209,25 @@ func (db *DB) GetNotificationStats() (map[string]interface{}, error) {
 	defer rows.Close()
 
 	typeCounts := make(map[string]int)
+	typeStatusCounts := make(map[string]map[string]int)
+	for _, t := range models.NotificationTypes {
+		typeCounts[string(t)] = 0
+		typeStatusCounts[string(t)] = make(map[string]int)
+	}
 	for rows.Next() {
-		var notifType string
+		var notifType, status string
 		var count int
-		if err := rows.Scan(&notifType, &count); err != nil {
+		if err := rows.Scan(&notifType, &status, &count); err != nil {
 			return nil, err
 		}
-		typeCounts[notifType] = count
+		typeCounts[notifType] += count
+		if typeStatusCounts[notifType] == nil {
+			typeStatusCounts[notifType] = make(map[string]int)
+		}
+		typeStatusCounts[notifType][status] = count
 	}
 	stats["by_type"] = typeCounts
+	stats["by_type_status"] = typeStatusCounts
 
 	// Notifications sent in last 24 hours
 	var last24h int
@@ -208,3 +298,301 @@ func (db *DB) GetNotificationStats() (map[string]interface{}, error) {
 
 	return stats, nil
 }
+
+// GetSlackMessage returns the Slack message of the alert posted for a ticket
+// on the named notification channel, with an empty TS if none was recorded
+func (db *DB) GetSlackMessage(ticketID int, notificationType models.NotificationType, channelName string) (models.SlackMessage, error) {
+	query := `
+		SELECT slack_channel, message_ts, batched
+		FROM slack_messages
+		WHERE ticket_id = ? AND notification_type = ? AND channel_name = ?
+	`
+
+	var message models.SlackMessage
+	err := db.QueryRow(query, ticketID, notificationType, channelName).Scan(&message.Channel, &message.TS, &message.Batched)
+	if err == sql.ErrNoRows {
+		return models.SlackMessage{}, nil
+	}
+	if err != nil {
+		return models.SlackMessage{}, err
+	}
+
+	return message, nil
+}
+
+// SaveSlackMessage records the Slack message of the alert posted for a
+// ticket so later reminders can be threaded under it
+func (db *DB) SaveSlackMessage(ticketID int, notificationType models.NotificationType, channelName string, message models.SlackMessage) error {
+	query := `
+		INSERT INTO slack_messages (ticket_id, notification_type, channel_name, slack_channel, message_ts, batched)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(ticket_id, notification_type, channel_name)
+		DO UPDATE SET
+			slack_channel = excluded.slack_channel,
+			message_ts = excluded.message_ts,
+			batched = excluded.batched,
+			created_at = CURRENT_TIMESTAMP
+	`
+
+	_, err := db.Exec(query, ticketID, notificationType, channelName, message.Channel, message.TS, message.Batched)
+	return err
+}
+
+// DeleteSlackMessage removes the stored reference to a ticket's alert so the
+// next alert starts a new message
+func (db *DB) DeleteSlackMessage(ticketID int, notificationType models.NotificationType, channelName string) error {
+	query := `
+		DELETE FROM slack_messages
+		WHERE ticket_id = ? AND notification_type = ? AND channel_name = ?
+	`
+
+	_, err := db.Exec(query, ticketID, notificationType, channelName)
+	return err
+}
+
+// RecordTicketAction stores an action taken on an alert from Slack
+func (db *DB) RecordTicketAction(action models.TicketAction) error {
+	query := `
+		INSERT INTO ticket_actions (
+			ticket_id,
+			notification_type,
+			action,
+			slack_user_id,
+			slack_user_name,
+			last_reply_unix,
+			until_unix
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	var until sql.NullInt64
+	if action.Until != nil {
+		until = sql.NullInt64{Int64: action.Until.Unix(), Valid: true}
+	}
+
+	_, err := db.Exec(query,
+		action.TicketID,
+		action.NotificationType,
+		action.Action,
+		action.SlackUserID,
+		action.SlackUserName,
+		action.LastReplyAt.Unix(),
+		until,
+	)
+	return err
+}
+
+// IsTicketSuppressed reports whether reminders for a ticket are suppressed
+// by an acknowledgement or assignment made since its last reply, or by a
+// snooze that has not yet expired
+func (db *DB) IsTicketSuppressed(ticketID int, lastReplyAt, now time.Time) (bool, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM ticket_actions
+		WHERE ticket_id = ?
+			AND (
+				(action IN ('acknowledge', 'assign') AND last_reply_unix = ?)
+				OR (action = 'snooze' AND until_unix > ?)
+			)
+	`
+
+	var count int
+	if err := db.QueryRow(query, ticketID, lastReplyAt.Unix(), now.Unix()).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// LoadTicketOwners sets the owner of each ticket to the Slack user who last
+// took it with Assign to me
+func (db *DB) LoadTicketOwners(tickets []models.Ticket) error {
+	if len(tickets) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(tickets))
+	for i, t := range tickets {
+		args[i] = t.ID
+	}
+
+	query := fmt.Sprintf(`
+		SELECT ticket_id, slack_user_id, slack_user_name
+		FROM ticket_actions
+		WHERE action = 'assign' AND ticket_id IN (%s)
+		ORDER BY id
+	`, placeholders(len(args)))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("owner query failed: %w", err)
+	}
+	defer rows.Close()
+
+	// Later assignments replace earlier ones
+	owners := make(map[int][2]string)
+	for rows.Next() {
+		var id int
+		var userID, userName sql.NullString
+		if err := rows.Scan(&id, &userID, &userName); err != nil {
+			return fmt.Errorf("scan failed: %w", err)
+		}
+		owners[id] = [2]string{userID.String, userName.String}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range tickets {
+		if owner, ok := owners[tickets[i].ID]; ok {
+			tickets[i].OwnerSlackID, tickets[i].OwnerName = owner[0], owner[1]
+		}
+	}
+	return nil
+}
+
+// GetPendingConversations returns the IDs of the conversations last seen in
+// pending status
+func (db *DB) GetPendingConversations() ([]int, error) {
+	rows, err := db.Query("SELECT ticket_id FROM pending_conversations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SavePendingConversations records the conversations currently in pending status
+func (db *DB) SavePendingConversations(ids []int, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO pending_conversations (ticket_id, last_seen_at)
+		VALUES (?, ?)
+		ON CONFLICT(ticket_id) DO UPDATE SET last_seen_at = excluded.last_seen_at
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, id := range ids {
+		if _, err := stmt.Exec(id, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ForgetPendingConversations removes conversations that no longer need to be
+// tracked
+func (db *DB) ForgetPendingConversations(ids []int) error {
+	for _, id := range ids {
+		if _, err := db.Exec("DELETE FROM pending_conversations WHERE ticket_id = ?", id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogBusinessHoursEvent records an event such as a queued burst or a digest
+// in business_hours_log
+func (db *DB) LogBusinessHoursEvent(eventType string, notificationsSent int) error {
+	query := `
+		INSERT INTO business_hours_log (event_type, notifications_sent)
+		VALUES (?, ?)
+	`
+
+	_, err := db.Exec(query, eventType, notificationsSent)
+	return err
+}
+
+// LastBusinessHoursEvent returns when an event was last logged, or the zero
+// time if it never was
+func (db *DB) LastBusinessHoursEvent(eventType string) (time.Time, error) {
+	query := `
+		SELECT event_time
+		FROM business_hours_log
+		WHERE event_type = ?
+		ORDER BY event_time DESC
+		LIMIT 1
+	`
+
+	var eventTime time.Time
+	err := db.QueryRow(query, eventType).Scan(&eventTime)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return eventTime, err
+}
+
+// GetSentTimes returns when the last alert of a notification type was sent
+// for each ticket
+func (db *DB) GetSentTimes(notificationType models.NotificationType) (map[int]time.Time, error) {
+	query := `
+		SELECT ticket_id, sent_at
+		FROM notifications
+		WHERE notification_type = ? AND sent_at IS NOT NULL
+	`
+
+	rows, err := db.Query(query, notificationType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sent := make(map[int]time.Time)
+	for rows.Next() {
+		var id int
+		var sentAt time.Time
+		if err := rows.Scan(&id, &sentAt); err != nil {
+			return nil, err
+		}
+		sent[id] = sentAt
+	}
+	return sent, rows.Err()
+}
+
+// LastBusinessHoursState returns the most recently logged of the given
+// event types, or "" if none was logged
+func (db *DB) LastBusinessHoursState(eventTypes ...string) (string, error) {
+	args := make([]interface{}, len(eventTypes))
+	for i, eventType := range eventTypes {
+		args[i] = eventType
+	}
+
+	query := fmt.Sprintf(`
+		SELECT event_type
+		FROM business_hours_log
+		WHERE event_type IN (%s)
+		ORDER BY id DESC
+		LIMIT 1
+	`, placeholders(len(eventTypes)))
+
+	var state string
+	err := db.QueryRow(query, args...).Scan(&state)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return state, err
+}
+
+// CountQueuedNotifications returns the number of notifications waiting for
+// business hours
+func (db *DB) CountQueuedNotifications() (int, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM notifications WHERE notification_status = 'queued'").Scan(&count)
+	return count, err
+}
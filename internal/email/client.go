@@ -0,0 +1,226 @@
+package email
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/voicetel/freescout-notifier/internal/channel"
+	"github.com/voicetel/freescout-notifier/internal/config"
+)
+
+// TLS modes supported in config.SMTPConfig.TLS
+const (
+	TLSStartTLS = "starttls"
+	TLSImplicit = "implicit"
+	TLSNone     = "none"
+)
+
+// Client delivers notifications as multipart emails over SMTP
+type Client struct {
+	name          string
+	cfg           config.SMTPConfig
+	retryAttempts int
+}
+
+func NewClient(name string, cfg config.SMTPConfig) *Client {
+	return &Client{
+		name:          name,
+		cfg:           cfg,
+		retryAttempts: cfg.RetryAttempts,
+	}
+}
+
+// Name implements channel.Channel
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Send implements channel.Channel
+func (c *Client) Send(n channel.Notification) error {
+	recipients := c.recipients(string(n.Ticket.NotificationType))
+	if len(recipients) == 0 {
+		return nil
+	}
+	return c.sendMail(recipients, []channel.Notification{n})
+}
+
+// SendBatch implements channel.BatchSender. Notifications are grouped by
+// recipient list so each list receives one email covering its tickets.
+// Notifications without recipients count as delivered, as in Send.
+func (c *Client) SendBatch(ns []channel.Notification) ([]bool, error) {
+	delivered := make([]bool, len(ns))
+	groups := make(map[string][]int)
+	recipientsByKey := make(map[string][]string)
+	var keys []string
+
+	for i, n := range ns {
+		recipients := c.recipients(string(n.Ticket.NotificationType))
+		if len(recipients) == 0 {
+			delivered[i] = true
+			continue
+		}
+		key := strings.Join(recipients, ",")
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+			recipientsByKey[key] = recipients
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	var firstErr error
+	for _, key := range keys {
+		group := make([]channel.Notification, len(groups[key]))
+		for j, i := range groups[key] {
+			group[j] = ns[i]
+		}
+		if err := c.sendMail(recipientsByKey[key], group); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		for _, i := range groups[key] {
+			delivered[i] = true
+		}
+	}
+	return delivered, firstErr
+}
+
+// SendDigest implements channel.DigestSender. The digest goes to the
+// default recipients, or to every per-type recipient when no default is set.
+func (c *Client) SendDigest(d channel.Digest) error {
+	recipients := c.cfg.To
+	if len(recipients) == 0 {
+		seen := make(map[string]bool)
+		for _, list := range c.cfg.RecipientsByType {
+			for _, rcpt := range list {
+				if !seen[rcpt] {
+					seen[rcpt] = true
+					recipients = append(recipients, rcpt)
+				}
+			}
+		}
+		sort.Strings(recipients)
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	message, err := buildDigestMessage(c.cfg.From, recipients, d, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to build message: %w", err)
+	}
+	return c.deliver(recipients, message)
+}
+
+// Test implements channel.Tester by connecting and authenticating without
+// sending a message
+func (c *Client) Test() error {
+	client, err := c.dial()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return client.Quit()
+}
+
+// recipients returns the recipient list for a notification type, falling
+// back to the default list
+func (c *Client) recipients(notificationType string) []string {
+	if recipients, ok := c.cfg.RecipientsByType[notificationType]; ok {
+		return recipients
+	}
+	return c.cfg.To
+}
+
+func (c *Client) sendMail(recipients []string, ns []channel.Notification) error {
+	message, err := buildMessage(c.cfg.From, recipients, ns)
+	if err != nil {
+		return fmt.Errorf("failed to build message: %w", err)
+	}
+	return c.deliver(recipients, message)
+}
+
+// deliver sends a rendered message to the recipients, retrying on failure
+func (c *Client) deliver(recipients []string, message []byte) error {
+	return channel.Retry(c.retryAttempts, func() error {
+		client, err := c.dial()
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		if err := client.Mail(c.cfg.From); err != nil {
+			return fmt.Errorf("MAIL FROM failed: %w", err)
+		}
+		for _, rcpt := range recipients {
+			if err := client.Rcpt(rcpt); err != nil {
+				return fmt.Errorf("RCPT TO %s failed: %w", rcpt, err)
+			}
+		}
+
+		w, err := client.Data()
+		if err != nil {
+			return fmt.Errorf("DATA failed: %w", err)
+		}
+		if _, err := w.Write(message); err != nil {
+			return fmt.Errorf("failed to write message: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("failed to finish message: %w", err)
+		}
+
+		return client.Quit()
+	})
+}
+
+// dial connects to the SMTP server, negotiates TLS and authenticates
+func (c *Client) dial() (*smtp.Client, error) {
+	addr := net.JoinHostPort(c.cfg.Host, fmt.Sprintf("%d", c.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: c.cfg.Host}
+	dialer := &net.Dialer{Timeout: c.cfg.Timeout.Duration}
+
+	var conn net.Conn
+	var err error
+	if c.cfg.TLS == TLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	if c.cfg.Timeout.Duration > 0 {
+		conn.SetDeadline(time.Now().Add(c.cfg.Timeout.Duration))
+	}
+
+	client, err := smtp.NewClient(conn, c.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+
+	if c.cfg.TLS == "" || c.cfg.TLS == TLSStartTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+
+	if c.cfg.Username != "" {
+		auth := smtp.PlainAuth("", c.cfg.Username, c.cfg.Password, c.cfg.Host)
+		if err := client.Auth(auth); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+
+	return client, nil
+}
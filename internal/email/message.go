@@ -0,0 +1,205 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/voicetel/freescout-notifier/internal/channel"
+)
+
+var htmlTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif;">
+{{range .}}<div style="margin-bottom: 24px;">
+<h3 style="margin: 0 0 8px 0;">{{.Headline}}</h3>
+<table cellpadding="4" style="border-collapse: collapse;">
+<tr><td><strong>Subject:</strong></td><td>{{.Subject}}</td></tr>
+<tr><td><strong>Customer:</strong></td><td>{{.Customer}}</td></tr>
+<tr><td><strong>Mailbox:</strong></td><td>{{.Mailbox}}</td></tr>
+{{if .Tags}}<tr><td><strong>Tags:</strong></td><td>{{.Tags}}</td></tr>
+{{end}}<tr><td><strong>Waiting for:</strong></td><td>{{.Waiting}}</td></tr>
+<tr><td><strong>Assigned to:</strong></td><td>{{.AssignedTo}}</td></tr>
+{{if .Escalation}}<tr><td><strong>Escalation:</strong></td><td>{{.Escalation}}</td></tr>
+{{end}}</table>
+<p><a href="{{.URL}}">Open in FreeScout</a></p>
+</div>
+{{end}}</body>
+</html>
+`))
+
+// entry holds the rendered fields of one notification
+type entry struct {
+	Headline   string
+	Subject    string
+	Customer   string
+	Mailbox    string
+	Tags       string
+	Waiting    string
+	AssignedTo string
+	Escalation string
+	URL        string
+}
+
+func newEntry(n channel.Notification) entry {
+	_, _, waitingFor := n.Describe()
+	return entry{
+		Headline:   n.Headline(),
+		Subject:    n.Ticket.Subject,
+		Customer:   n.Ticket.CustomerName,
+		Mailbox:    n.Mailbox(),
+		Tags:       strings.Join(n.Ticket.Tags, ", "),
+		Waiting:    fmt.Sprintf("%s for %s", waitingFor, n.WaitingTime()),
+		AssignedTo: n.AssignedTo(),
+		Escalation: n.TierName,
+		URL:        n.TicketURL,
+	}
+}
+
+// subject returns the email subject line for a set of notifications
+func subject(ns []channel.Notification) string {
+	if len(ns) == 1 {
+		return fmt.Sprintf("[FreeScout] %s: %s", ns[0].Title(), ns[0].Ticket.Subject)
+	}
+	return fmt.Sprintf("[FreeScout] %d tickets need attention", len(ns))
+}
+
+// buildMessage renders a multipart/alternative email with plain-text and
+// HTML bodies covering every notification in ns
+func buildMessage(from string, to []string, ns []channel.Notification) ([]byte, error) {
+	entries := make([]entry, 0, len(ns))
+	for _, n := range ns {
+		entries = append(entries, newEntry(n))
+	}
+
+	var text strings.Builder
+	for i, e := range entries {
+		if i > 0 {
+			text.WriteString("\n")
+		}
+		fmt.Fprintf(&text, "%s\n", e.Headline)
+		fmt.Fprintf(&text, "Subject: %s\n", e.Subject)
+		fmt.Fprintf(&text, "Customer: %s\n", e.Customer)
+		fmt.Fprintf(&text, "Mailbox: %s\n", e.Mailbox)
+		if e.Tags != "" {
+			fmt.Fprintf(&text, "Tags: %s\n", e.Tags)
+		}
+		fmt.Fprintf(&text, "Waiting for: %s\n", e.Waiting)
+		fmt.Fprintf(&text, "Assigned to: %s\n", e.AssignedTo)
+		if e.Escalation != "" {
+			fmt.Fprintf(&text, "Escalation: %s\n", e.Escalation)
+		}
+		fmt.Fprintf(&text, "View ticket: %s\n", e.URL)
+	}
+
+	var html bytes.Buffer
+	if err := htmlTemplate.Execute(&html, entries); err != nil {
+		return nil, err
+	}
+
+	return assemble(from, to, subject(ns), text.String(), html.Bytes())
+}
+
+var digestTemplate = template.Must(template.New("digest").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif;">
+<h2 style="margin: 0 0 16px 0;">{{.Headline}}</h2>
+{{range .Assignees}}<h3 style="margin: 16px 0 8px 0;">{{.Summary}}</h3>
+<ul>
+{{range .Tickets}}<li><a href="{{.URL}}">{{.Line}}</a></li>
+{{end}}</ul>
+{{end}}{{if .Mailboxes}}<h3 style="margin: 16px 0 8px 0;">By mailbox</h3>
+<ul>
+{{range .Mailboxes}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}</body>
+</html>
+`))
+
+// digestGroup holds the rendered lines of one assignee in a digest
+type digestGroup struct {
+	Summary string
+	Tickets []digestLine
+}
+
+type digestLine struct {
+	Line string
+	URL  string
+}
+
+// buildDigestMessage renders a digest as a multipart/alternative email
+func buildDigestMessage(from string, to []string, d channel.Digest, now time.Time) ([]byte, error) {
+	data := struct {
+		Headline  string
+		Assignees []digestGroup
+		Mailboxes []string
+	}{Headline: d.Headline()}
+
+	for _, g := range d.ByAssignee() {
+		group := digestGroup{Summary: g.Summary()}
+		for _, t := range g.Tickets {
+			group.Tickets = append(group.Tickets, digestLine{Line: t.Line(now), URL: t.TicketURL})
+		}
+		data.Assignees = append(data.Assignees, group)
+	}
+	for _, g := range d.ByMailbox() {
+		data.Mailboxes = append(data.Mailboxes, g.Summary())
+	}
+
+	var html bytes.Buffer
+	if err := digestTemplate.Execute(&html, data); err != nil {
+		return nil, err
+	}
+
+	return assemble(from, to, "[FreeScout] "+strings.TrimPrefix(d.Headline(), "📋 "), d.Text(now), html.Bytes())
+}
+
+// assemble builds the email headers and the plain-text and HTML parts
+func assemble(from string, to []string, subjectLine, text string, html []byte) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := writePart(mw, "text/plain; charset=utf-8", []byte(text)); err != nil {
+		return nil, err
+	}
+	if err := writePart(mw, "text/html; charset=utf-8", html); err != nil {
+		return nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subjectLine))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n", mw.Boundary())
+	fmt.Fprintf(&msg, "\r\n")
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
+
+func writePart(mw *multipart.Writer, contentType string, content []byte) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write(content); err != nil {
+		return err
+	}
+	return qp.Close()
+}
@@ -3,26 +3,50 @@ package models
 import "time"
 
 type Ticket struct {
-	ID               int
-	Number           int
-	Subject          string
-	CustomerEmail    string
-	CustomerName     string
-	AssignedUserID   *int
-	AssignedUserName string
-	LastReplyAt      time.Time
+	ID                int
+	Number            int
+	Subject           string
+	CustomerEmail     string
+	CustomerID        int
+	CustomerName      string
+	AssignedUserID    *int
+	AssignedUserName  string
+	LastReplyAt       time.Time
 	MinutesSinceReply int
-	MailboxID        int
-	NotificationType NotificationType
+	MailboxID         int
+	MailboxName       string
+	Tags              []string
+	VIP               bool
+	Priority          bool // Has one of the configured priority tags
+	NotificationType  NotificationType
+	EscalationTier    int    // 1-based escalation tier reached, 0 when below the first tier
+	OwnerSlackID      string // Slack user who took the ticket with Assign to me, "" if nobody has
+	OwnerName         string
+}
+
+// Mailbox is a FreeScout mailbox
+type Mailbox struct {
+	ID   int
+	Name string
 }
 
 type NotificationType string
 
 const (
-	OpenNoAgentResponse     NotificationType = "open_no_agent_response"
+	OpenNoAgentResponse       NotificationType = "open_no_agent_response"
 	PendingNoCustomerResponse NotificationType = "pending_no_customer_response"
+	UnassignedConversation    NotificationType = "unassigned_conversation"
+	ReturnedFromPending       NotificationType = "returned_from_pending"
 )
 
+// NotificationTypes lists every notification type
+var NotificationTypes = []NotificationType{
+	OpenNoAgentResponse,
+	PendingNoCustomerResponse,
+	UnassignedConversation,
+	ReturnedFromPending,
+}
+
 type Notification struct {
 	ID                 int
 	TicketID           int
@@ -36,21 +60,65 @@ type Notification struct {
 	AssignedUser       string
 	MinutesWaiting     int
 	ThresholdMinutes   int
+	EscalationTier     int
+	LastReplyAt        *time.Time
 	TicketData         string // JSON
 }
 
+// Tone sets the wording of an escalated notification
+type Tone string
+
+const (
+	ToneNormal  Tone = "normal"
+	ToneWarning Tone = "warning"
+	ToneUrgent  Tone = "urgent"
+)
+
 type NotificationStatus string
 
 const (
-	StatusPending NotificationStatus = "pending"
-	StatusQueued  NotificationStatus = "queued"
-	StatusSent    NotificationStatus = "sent"
+	StatusPending  NotificationStatus = "pending"
+	StatusQueued   NotificationStatus = "queued"
+	StatusSent     NotificationStatus = "sent"
+	StatusResolved NotificationStatus = "resolved"
+	StatusExcluded NotificationStatus = "excluded" // Skipped by a tag, mailbox or assignee filter without being answered
 )
 
 type RunStats struct {
-	TicketsChecked      int
-	NotificationsSent   int
-	NotificationsQueued int
-	Errors              int
-	Duration            time.Duration
+	TicketsChecked        int
+	NotificationsSent     int
+	NotificationsQueued   int
+	NotificationsResolved int
+	Errors                int
+	Duration              time.Duration
+}
+
+type TicketActionType string
+
+const (
+	ActionAcknowledge TicketActionType = "acknowledge"
+	ActionSnooze      TicketActionType = "snooze"
+	ActionAssign      TicketActionType = "assign"
+)
+
+// TicketAction is an action taken on an alert from Slack. Acknowledge and
+// assign suppress reminders until the ticket receives a new reply; snooze
+// suppresses them until Until. Assign also makes the Slack user the owner
+// shown in later alerts.
+type TicketAction struct {
+	TicketID         int
+	NotificationType NotificationType
+	Action           TicketActionType
+	SlackUserID      string
+	SlackUserName    string
+	LastReplyAt      time.Time
+	Until            *time.Time
+}
+
+// SlackMessage references the Slack message an alert was posted as.
+// Batched messages list several tickets of a queued burst.
+type SlackMessage struct {
+	Channel string
+	TS      string
+	Batched bool
 }
@@ -2,7 +2,7 @@ package notifier
 
 import (
 	"encoding/json"
-	"log"
+	"fmt"
 	"os"
 	"time"
 
@@ -11,24 +11,28 @@ import (
 
 type BusinessHours struct {
 	enabled      bool
-	startHour    int
-	endHour      int
+	alwaysOpen   bool
 	timezone     *time.Location
-	workDays     map[time.Weekday]bool
+	schedule     map[time.Weekday][]config.TimeRange
 	holidays     map[string]bool
+	holidayRules []HolidayRule
 	notifyOnOpen bool
 }
 
+// HolidaysFile lists holidays as dates such as "2025-12-25" and as rules
+// that recur every year
 type HolidaysFile struct {
-	Holidays []string `json:"holidays"`
+	Holidays []string      `json:"holidays"`
+	Rules    []HolidayRule `json:"rules"`
 }
 
-func NewBusinessHours(cfg config.BusinessHoursConfig) *BusinessHours {
+// NewBusinessHours loads the business hours and holidays. A holidays file
+// that cannot be loaded is an error, since running without it would send
+// alerts on every holiday.
+func NewBusinessHours(cfg config.BusinessHoursConfig) (*BusinessHours, error) {
 	bh := &BusinessHours{
 		enabled:      cfg.Enabled,
-		startHour:    cfg.StartHour,
-		endHour:      cfg.EndHour,
-		workDays:     make(map[time.Weekday]bool),
+		alwaysOpen:   cfg.AlwaysOpen,
 		holidays:     make(map[string]bool),
 		notifyOnOpen: cfg.NotifyOnOpen,
 	}
@@ -40,19 +44,21 @@ func NewBusinessHours(cfg config.BusinessHoursConfig) *BusinessHours {
 	}
 	bh.timezone = loc
 
-	// Set work days
-	for _, day := range cfg.WorkDays {
-		bh.workDays[day] = true
+	// Set the hours of each work day
+	schedule, err := cfg.WeeklySchedule()
+	if err != nil {
+		return nil, err
 	}
+	bh.schedule = schedule
 
-	// Load holidays - FIX: Check error return value
+	// Load holidays
 	if cfg.HolidaysFile != "" {
 		if err := bh.loadHolidays(cfg.HolidaysFile); err != nil {
-			log.Printf("Warning: failed to load holidays file %s: %v", cfg.HolidaysFile, err)
+			return nil, fmt.Errorf("failed to load holidays file %s: %w", cfg.HolidaysFile, err)
 		}
 	}
 
-	return bh
+	return bh, nil
 }
 
 func (bh *BusinessHours) IsBusinessHours(t time.Time) bool {
@@ -60,38 +66,80 @@ func (bh *BusinessHours) IsBusinessHours(t time.Time) bool {
 		return true
 	}
 
-	localTime := t.In(bh.timezone)
+	_, ok := bh.openSince(t)
+	return ok
+}
 
-	// Check if holiday
-	dateStr := localTime.Format("2006-01-02")
-	if bh.holidays[dateStr] {
-		return false
+// OpenedAt returns when business hours first opened on the work day t belongs
+// to, and false when t is outside business hours. Hours that run past
+// midnight belong to the day they started on. Without business hours, or
+// when always open, the day opens at midnight.
+func (bh *BusinessHours) OpenedAt(t time.Time) (time.Time, bool) {
+	if !bh.enabled {
+		localTime := t.In(bh.timezone)
+		return time.Date(localTime.Year(), localTime.Month(), localTime.Day(), 0, 0, 0, 0, bh.timezone), true
 	}
+	return bh.openSince(t)
+}
 
-	// Check if work day
-	if !bh.workDays[localTime.Weekday()] {
-		return false
+// openSince finds the work day that t falls in, checking the hours of the
+// day of t and the overnight hours of the day before, and returns when that
+// day's first range opened
+func (bh *BusinessHours) openSince(t time.Time) (time.Time, bool) {
+	localTime := t.In(bh.timezone)
+	minute := localTime.Hour()*60 + localTime.Minute()
+	today := time.Date(localTime.Year(), localTime.Month(), localTime.Day(), 0, 0, 0, 0, bh.timezone)
+	yesterday := time.Date(localTime.Year(), localTime.Month(), localTime.Day()-1, 0, 0, 0, 0, bh.timezone)
+
+	if bh.alwaysOpen {
+		if bh.isHoliday(today) {
+			return time.Time{}, false
+		}
+		return today, true
 	}
 
-	// Check if within hours
-	hour := localTime.Hour()
-	return hour >= bh.startHour && hour < bh.endHour
+	if opened, ok := bh.openedOn(today, minute, config.TimeRange.Contains); ok {
+		return opened, true
+	}
+	return bh.openedOn(yesterday, minute, config.TimeRange.ContainsNextDay)
 }
 
-func (bh *BusinessHours) IsStartOfBusinessDay(t time.Time) bool {
-	if !bh.enabled || !bh.notifyOnOpen {
-		return false
+// openedOn returns when the first range of day opened, when day is a work
+// day that is not a holiday and one of its ranges contains the minute
+func (bh *BusinessHours) openedOn(day time.Time, minute int, contains func(config.TimeRange, int) bool) (time.Time, bool) {
+	// Check if holiday
+	if bh.isHoliday(day) {
+		return time.Time{}, false
 	}
 
-	localTime := t.In(bh.timezone)
+	ranges := bh.schedule[day.Weekday()]
+	for _, r := range ranges {
+		if contains(r, minute) {
+			return time.Date(day.Year(), day.Month(), day.Day(), 0, ranges[0].Start, 0, 0, bh.timezone), true
+		}
+	}
+	return time.Time{}, false
+}
 
-	// Must be business hours
-	if !bh.IsBusinessHours(t) {
-		return false
+// isHoliday reports whether the date of day is a listed holiday or matches
+// a holiday rule
+func (bh *BusinessHours) isHoliday(day time.Time) bool {
+	if bh.holidays[day.Format("2006-01-02")] {
+		return true
+	}
+	for _, rule := range bh.holidayRules {
+		if rule.On(day) {
+			return true
+		}
 	}
+	return false
+}
 
-	// Check if within first 5 minutes of start hour
-	return localTime.Hour() == bh.startHour && localTime.Minute() < 5
+// WeekStart returns midnight on the Monday of the week containing t
+func (bh *BusinessHours) WeekStart(t time.Time) time.Time {
+	localTime := t.In(bh.timezone)
+	offset := (int(localTime.Weekday()) + 6) % 7 // Days since Monday
+	return time.Date(localTime.Year(), localTime.Month(), localTime.Day()-offset, 0, 0, 0, 0, bh.timezone)
 }
 
 func (bh *BusinessHours) loadHolidays(filename string) error {
@@ -109,5 +157,12 @@ func (bh *BusinessHours) loadHolidays(filename string) error {
 		bh.holidays[holiday] = true
 	}
 
+	for i := range hf.Rules {
+		if err := hf.Rules[i].validate(); err != nil {
+			return fmt.Errorf("rules[%d]: %w", i, err)
+		}
+	}
+	bh.holidayRules = hf.Rules
+
 	return nil
 }
@@ -0,0 +1,169 @@
+package notifier
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/voicetel/freescout-notifier/internal/config"
+)
+
+const (
+	// Every day from 22:00 to 06:00 the next morning
+	nightShift = `{"start_hour": 22, "end_hour": 6, "work_days": [0, 1, 2, 3, 4, 5, 6]}`
+	// Monday to Friday from 22:00 to 06:00 the next morning
+	weeknightShift = `{"schedule": {"mon": ["22:00-06:00"], "tue": ["22:00-06:00"], "wed": ["22:00-06:00"], "thu": ["22:00-06:00"], "fri": ["22:00-06:00"]}}`
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s not available: %v", name, err)
+	}
+	return loc
+}
+
+// newTestBusinessHours builds business hours in America/Chicago from the
+// JSON of a business_hours config and a list of holiday dates
+func newTestBusinessHours(t *testing.T, cfgJSON string, holidays []string) *BusinessHours {
+	t.Helper()
+
+	var cfg config.BusinessHoursConfig
+	if err := json.Unmarshal([]byte(cfgJSON), &cfg); err != nil {
+		t.Fatalf("unmarshal config: %v", err)
+	}
+	cfg.Enabled = true
+	cfg.Timezone = "America/Chicago"
+
+	if len(holidays) > 0 {
+		data, err := json.Marshal(HolidaysFile{Holidays: holidays})
+		if err != nil {
+			t.Fatalf("marshal holidays: %v", err)
+		}
+		cfg.HolidaysFile = filepath.Join(t.TempDir(), "holidays.json")
+		if err := os.WriteFile(cfg.HolidaysFile, data, 0o644); err != nil {
+			t.Fatalf("write holidays: %v", err)
+		}
+	}
+
+	bh, err := NewBusinessHours(cfg)
+	if err != nil {
+		t.Fatalf("NewBusinessHours: %v", err)
+	}
+	return bh
+}
+
+func TestOpenedAt(t *testing.T) {
+	chicago := mustLoadLocation(t, "America/Chicago")
+	local := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, chicago)
+	}
+
+	tests := []struct {
+		name       string
+		cfg        string
+		holidays   []string
+		at         time.Time
+		wantOpen   bool
+		wantOpened time.Time
+	}{
+		// Clocks spring forward from 02:00 to 03:00 on 2026-03-08
+		{"spring forward before the jump", nightShift, nil, local(2026, 3, 8, 1, 30), true, local(2026, 3, 7, 22, 0)},
+		{"spring forward after the jump", nightShift, nil, local(2026, 3, 8, 3, 30), true, local(2026, 3, 7, 22, 0)},
+		{"spring forward last minute", nightShift, nil, local(2026, 3, 8, 5, 59), true, local(2026, 3, 7, 22, 0)},
+		{"spring forward closed", nightShift, nil, local(2026, 3, 8, 6, 0), false, time.Time{}},
+		{"spring forward next shift", nightShift, nil, local(2026, 3, 8, 22, 0), true, local(2026, 3, 8, 22, 0)},
+
+		// Clocks fall back from 02:00 to 01:00 on 2026-11-01, so 01:30
+		// happens twice
+		{"fall back first 01:30", nightShift, nil, time.Date(2026, 11, 1, 6, 30, 0, 0, time.UTC), true, local(2026, 10, 31, 22, 0)},
+		{"fall back second 01:30", nightShift, nil, time.Date(2026, 11, 1, 7, 30, 0, 0, time.UTC), true, local(2026, 10, 31, 22, 0)},
+		{"fall back last minute", nightShift, nil, local(2026, 11, 1, 5, 59), true, local(2026, 10, 31, 22, 0)},
+		{"fall back closed", nightShift, nil, local(2026, 11, 1, 6, 0), false, time.Time{}},
+
+		// Friday 2026-12-25 is a holiday, so its shift never opens, while
+		// Thursday's shift runs into the holiday morning
+		{"overnight into a holiday", weeknightShift, []string{"2026-12-25"}, local(2026, 12, 25, 2, 0), true, local(2026, 12, 24, 22, 0)},
+		{"holiday evening", weeknightShift, []string{"2026-12-25"}, local(2026, 12, 25, 23, 0), false, time.Time{}},
+		{"overnight from a holiday", weeknightShift, []string{"2026-12-25"}, local(2026, 12, 26, 2, 0), false, time.Time{}},
+
+		// Friday's shift runs into Saturday, but Sunday has no shift to run
+		// into Monday
+		{"overnight into a non-work day", weeknightShift, nil, local(2026, 10, 17, 2, 0), true, local(2026, 10, 16, 22, 0)},
+		{"overnight from a non-work day", weeknightShift, nil, local(2026, 10, 19, 2, 0), false, time.Time{}},
+		{"work day after a non-work day", weeknightShift, nil, local(2026, 10, 19, 22, 0), true, local(2026, 10, 19, 22, 0)},
+
+		// 24:00 ends the range at midnight without running into the next day
+		{"all day at midnight", `{"schedule": {"mon": ["00:00-24:00"]}}`, nil, local(2026, 10, 19, 0, 0), true, local(2026, 10, 19, 0, 0)},
+		{"all day last minute", `{"schedule": {"mon": ["00:00-24:00"]}}`, nil, local(2026, 10, 19, 23, 59), true, local(2026, 10, 19, 0, 0)},
+		{"all day next day", `{"schedule": {"mon": ["00:00-24:00"]}}`, nil, local(2026, 10, 20, 0, 0), false, time.Time{}},
+
+		// Equal start and end hours open for 24 hours from the start
+		{"flat midnight to midnight", `{"start_hour": 0, "end_hour": 0, "work_days": [1]}`, nil, local(2026, 10, 19, 12, 0), true, local(2026, 10, 19, 0, 0)},
+		{"flat midnight to midnight next day", `{"start_hour": 0, "end_hour": 0, "work_days": [1]}`, nil, local(2026, 10, 20, 0, 0), false, time.Time{}},
+		{"flat 9 to 9 next morning", `{"start_hour": 9, "end_hour": 9, "work_days": [1]}`, nil, local(2026, 10, 20, 8, 59), true, local(2026, 10, 19, 9, 0)},
+		{"flat 9 to 9 closed", `{"start_hour": 9, "end_hour": 9, "work_days": [1]}`, nil, local(2026, 10, 20, 9, 0), false, time.Time{}},
+
+		// always_open ignores the hours but not holidays
+		{"always open", `{"always_open": true}`, []string{"2026-12-25"}, local(2026, 12, 24, 23, 0), true, local(2026, 12, 24, 0, 0)},
+		{"always open on a holiday", `{"always_open": true}`, []string{"2026-12-25"}, local(2026, 12, 25, 12, 0), false, time.Time{}},
+		{"always open after a holiday", `{"always_open": true}`, []string{"2026-12-25"}, local(2026, 12, 26, 0, 0), true, local(2026, 12, 26, 0, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bh := newTestBusinessHours(t, tt.cfg, tt.holidays)
+
+			opened, ok := bh.OpenedAt(tt.at)
+			if ok != tt.wantOpen {
+				t.Fatalf("OpenedAt(%s) open = %t, want %t", tt.at.In(chicago), ok, tt.wantOpen)
+			}
+			if ok && !opened.Equal(tt.wantOpened) {
+				t.Errorf("OpenedAt(%s) = %s, want %s", tt.at.In(chicago), opened, tt.wantOpened)
+			}
+			if got := bh.IsBusinessHours(tt.at); got != tt.wantOpen {
+				t.Errorf("IsBusinessHours(%s) = %t, want %t", tt.at.In(chicago), got, tt.wantOpen)
+			}
+		})
+	}
+}
+
+func TestNewBusinessHoursHolidaysErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		holidays string // Contents of the holidays file, "" for a missing file
+		wantErr  string
+	}{
+		{"missing file", "", "no such file"},
+		{"invalid JSON", `{"holidays": [`, "unexpected end of JSON input"},
+		{"invalid rule after dates", `{"holidays": ["2026-12-25"], "rules": [{"month": 13, "day": 1}]}`, "rules[0]: month must be 1-12"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.BusinessHoursConfig{
+				Enabled:      true,
+				Timezone:     "UTC",
+				HolidaysFile: filepath.Join(t.TempDir(), "holidays.json"),
+			}
+			if tt.holidays != "" {
+				if err := os.WriteFile(cfg.HolidaysFile, []byte(tt.holidays), 0o644); err != nil {
+					t.Fatalf("write holidays: %v", err)
+				}
+			}
+
+			bh, err := NewBusinessHours(cfg)
+			if err == nil {
+				t.Fatalf("NewBusinessHours() = %v, want error containing %q", bh, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewBusinessHours() error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package notifier
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/voicetel/freescout-notifier/internal/channel"
+	"github.com/voicetel/freescout-notifier/internal/config"
+	"github.com/voicetel/freescout-notifier/internal/database"
+	"github.com/voicetel/freescout-notifier/internal/email"
+	"github.com/voicetel/freescout-notifier/internal/models"
+	"github.com/voicetel/freescout-notifier/internal/pagerduty"
+	"github.com/voicetel/freescout-notifier/internal/slack"
+	"github.com/voicetel/freescout-notifier/internal/teams"
+	"github.com/voicetel/freescout-notifier/internal/webhook"
+)
+
+// newChannel builds the delivery channel described by cfg. localDB may be
+// nil when no state needs to be persisted, such as for connection tests.
+func newChannel(cfg config.ChannelConfig, localDB *database.DB) (channel.Channel, error) {
+	switch cfg.Type {
+	case config.ChannelTypeSlack:
+		var store slack.MessageStore
+		if localDB != nil {
+			store = localDB
+		}
+		return slack.NewClient(cfg.Name, *cfg.Slack, store), nil
+	case config.ChannelTypeTeams:
+		return teams.NewClient(cfg.Name, *cfg.Teams), nil
+	case config.ChannelTypeSMTP:
+		return email.NewClient(cfg.Name, *cfg.SMTP), nil
+	case config.ChannelTypeWebhook:
+		return webhook.NewClient(cfg.Name, *cfg.Webhook), nil
+	case config.ChannelTypePagerDuty:
+		return pagerduty.NewClient(cfg.Name, *cfg.PagerDuty), nil
+	default:
+		return nil, fmt.Errorf("channel %q: unknown type %q", cfg.Name, cfg.Type)
+	}
+}
+
+// target is a delivery channel together with its configuration
+type target struct {
+	channel.Channel
+	cfg config.ChannelConfig
+}
+
+// handles reports whether the channel is configured for the notification's type
+func (t target) handles(notification channel.Notification) bool {
+	if len(t.cfg.NotificationTypes) == 0 {
+		return true
+	}
+	for _, notificationType := range t.cfg.NotificationTypes {
+		if notificationType == string(notification.Ticket.NotificationType) {
+			return true
+		}
+	}
+	return false
+}
+
+// accepts reports whether the notification passes the channel's filters
+func (t target) accepts(notification channel.Notification) bool {
+	waiting := time.Duration(notification.Ticket.MinutesSinceReply) * time.Minute
+	return t.handles(notification) && waiting >= t.cfg.MinWait.Duration
+}
+
+// newTargets builds every configured notification channel
+func newTargets(cfg *config.Config, localDB *database.DB) ([]target, error) {
+	var targets []target
+	for _, chCfg := range cfg.ChannelConfigs() {
+		ch, err := newChannel(chCfg, localDB)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target{Channel: ch, cfg: chCfg})
+	}
+	return targets, nil
+}
+
+// dispatch fans a notification out to the channels it is routed to
+func (n *Notifier) dispatch(notification channel.Notification) error {
+	return n.sendTo(n.channels, notification)
+}
+
+// sendTo delivers a notification to each of the given targets it is routed
+// to and whose filters accept it. Failures on individual channels are logged;
+// an error is only returned when every attempted channel failed, so the
+// ticket is retried on the next run.
+func (n *Notifier) sendTo(targets []target, notification channel.Notification) error {
+	if len(targets) == 0 {
+		return fmt.Errorf("no notification channels configured")
+	}
+
+	attempted := 0
+	var errs []error
+	for _, t := range targets {
+		if !n.delivers(t, notification) {
+			continue
+		}
+		attempted++
+		if err := t.Send(notification); err != nil {
+			log.Printf("Error sending ticket %d to channel %s: %v", notification.Ticket.ID, t.Name(), err)
+			errs = append(errs, fmt.Errorf("%s: %w", t.Name(), err))
+		}
+	}
+
+	if attempted > 0 && len(errs) == attempted {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// alertedTargets returns the targets that received the alerts of a ticket,
+// as stored with its last alert: those it was routed to at its last
+// escalation tier or at any of the tiers it passed through before
+func (n *Notifier) alertedTargets(ticket models.Ticket) []target {
+	var alerted []target
+	for _, t := range n.channels {
+		for tier := ticket.EscalationTier; tier >= 0; tier-- {
+			atTier := ticket
+			atTier.EscalationTier = tier
+			if n.delivers(t, n.newNotification(atTier, models.StatusSent)) {
+				alerted = append(alerted, t)
+				break
+			}
+		}
+	}
+	return alerted
+}
+
+// resolveWith passes a resolved notification to each of the given targets
+// that can resolve alerts
+func resolveWith(targets []target, notification channel.Notification) {
+	for _, t := range targets {
+		resolver, ok := t.Channel.(channel.Resolver)
+		if !ok {
+			continue
+		}
+		if err := resolver.Resolve(notification); err != nil {
+			log.Printf("Error resolving ticket %d on channel %s: %v", notification.Ticket.ID, t.Name(), err)
+		}
+	}
+}
+
+// TestChannels sends a test message through every channel that supports it
+func TestChannels(cfg *config.Config) error {
+	targets, err := newTargets(cfg, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range targets {
+		tester, ok := t.Channel.(channel.Tester)
+		if !ok {
+			log.Printf("Channel %s does not support connection tests, skipping", t.Name())
+			continue
+		}
+		if err := tester.Test(); err != nil {
+			return fmt.Errorf("channel %s: %w", t.Name(), err)
+		}
+		log.Printf("Channel %s test successful", t.Name())
+	}
+
+	return nil
+}
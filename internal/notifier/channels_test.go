@@ -0,0 +1,136 @@
+package notifier
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/voicetel/freescout-notifier/internal/channel"
+	"github.com/voicetel/freescout-notifier/internal/config"
+	"github.com/voicetel/freescout-notifier/internal/models"
+	"github.com/voicetel/freescout-notifier/internal/rules"
+)
+
+// fakeChannel records the tickets it is asked to resolve
+type fakeChannel struct {
+	name     string
+	resolved []int
+}
+
+func (f *fakeChannel) Name() string                      { return f.name }
+func (f *fakeChannel) Send(n channel.Notification) error { return nil }
+
+func (f *fakeChannel) Resolve(n channel.Notification) error {
+	f.resolved = append(f.resolved, n.Ticket.ID)
+	return nil
+}
+
+// newRoutingNotifier builds a notifier delivering to fake channels with the
+// given names
+func newRoutingNotifier(t *testing.T, cfg *config.Config, names ...string) (*Notifier, map[string]*fakeChannel) {
+	t.Helper()
+
+	engine, err := rules.New(cfg.Rules)
+	if err != nil {
+		t.Fatalf("rules.New: %v", err)
+	}
+
+	n := &Notifier{config: cfg, rules: engine}
+	fakes := make(map[string]*fakeChannel)
+	for _, name := range names {
+		fake := &fakeChannel{name: name}
+		fakes[name] = fake
+		chCfg := config.ChannelConfig{Name: name}
+		for _, c := range cfg.Channels {
+			if c.Name == name {
+				chCfg = c
+			}
+		}
+		n.channels = append(n.channels, target{Channel: fake, cfg: chCfg})
+	}
+	return n, fakes
+}
+
+func TestAlertedTargets(t *testing.T) {
+	cfg := &config.Config{
+		Channels: []config.ChannelConfig{
+			{Name: "pending-only", NotificationTypes: []string{string(models.PendingNoCustomerResponse)}},
+			{Name: "slow", MinWait: config.Duration{Duration: 4 * time.Hour}},
+		},
+		Routing: config.RoutingConfig{
+			Default:   []string{"support", "slow", "pending-only"},
+			Mailboxes: map[int][]string{2: {"billing"}},
+			Tags:      map[string][]string{"outage": {"ops"}},
+		},
+		Escalation: []config.EscalationTier{
+			{Name: "Warning", After: config.Duration{Duration: time.Hour}},
+			{Name: "Page", After: config.Duration{Duration: 2 * time.Hour}, Channels: []string{"pagerduty"}},
+		},
+		Rules: []config.RuleConfig{
+			{Name: "vip", When: "vip", Action: string(rules.ActionNotify), Channels: []string{"vip-desk"}},
+		},
+	}
+	names := []string{"support", "billing", "ops", "pagerduty", "vip-desk", "pending-only", "slow"}
+
+	tests := []struct {
+		name   string
+		ticket models.Ticket
+		want   []string
+	}{
+		{"default route", models.Ticket{MailboxID: 1, MinutesSinceReply: 30}, []string{"support"}},
+		{"channel filters", models.Ticket{MailboxID: 1, MinutesSinceReply: 300}, []string{"support", "slow"}},
+		{"mailbox route", models.Ticket{MailboxID: 2, MinutesSinceReply: 30}, []string{"billing"}},
+		{"tag route", models.Ticket{MailboxID: 2, Tags: []string{"Outage"}, MinutesSinceReply: 30}, []string{"ops"}},
+		{"rule notify", models.Ticket{MailboxID: 2, VIP: true, MinutesSinceReply: 30}, []string{"vip-desk"}},
+		{"tier without channels", models.Ticket{MailboxID: 2, MinutesSinceReply: 90, EscalationTier: 1}, []string{"billing"}},
+		{"tier with channels and the tiers before", models.Ticket{MailboxID: 2, MinutesSinceReply: 150, EscalationTier: 2}, []string{"billing", "pagerduty"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, _ := newRoutingNotifier(t, cfg, names...)
+			tt.ticket.NotificationType = models.OpenNoAgentResponse
+
+			var got []string
+			for _, target := range n.alertedTargets(tt.ticket) {
+				got = append(got, target.Name())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("alertedTargets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveWithAlertedTargets(t *testing.T) {
+	cfg := &config.Config{
+		Routing: config.RoutingConfig{
+			Default: []string{"slack"},
+			Tags:    map[string][]string{"outage": {"pagerduty-ops"}},
+		},
+		Escalation: []config.EscalationTier{
+			{Name: "Page", After: config.Duration{Duration: 2 * time.Hour}, Channels: []string{"pagerduty-managers"}},
+		},
+	}
+	n, fakes := newRoutingNotifier(t, cfg, "slack", "pagerduty-ops", "pagerduty-managers")
+
+	tickets := []models.Ticket{
+		{ID: 1, NotificationType: models.OpenNoAgentResponse, MinutesSinceReply: 30},
+		{ID: 2, NotificationType: models.OpenNoAgentResponse, MinutesSinceReply: 30, Tags: []string{"outage"}},
+		{ID: 3, NotificationType: models.OpenNoAgentResponse, MinutesSinceReply: 150, EscalationTier: 1},
+	}
+	for _, ticket := range tickets {
+		resolveWith(n.alertedTargets(ticket), n.newNotification(ticket, models.StatusResolved))
+	}
+
+	want := map[string][]int{
+		"slack":              {1, 3},
+		"pagerduty-ops":      {2},
+		"pagerduty-managers": {3},
+	}
+	for name, fake := range fakes {
+		if !reflect.DeepEqual(fake.resolved, want[name]) {
+			t.Errorf("%s resolved %v, want %v", name, fake.resolved, want[name])
+		}
+	}
+}
@@ -45,6 +45,42 @@ func CleanupOldNotifications(db *database.DB, retentionDays int) error {
 		log.Printf("Cleaned up %d old business hours log entries", rowsAffected)
 	}
 
+	// Cleanup old Slack actions
+	actionsQuery := `
+		DELETE FROM ticket_actions
+		WHERE created_at < datetime('now', '-' || ? || ' days')
+	`
+
+	result, err = db.Exec(actionsQuery, retentionDays)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err = result.RowsAffected()
+	if err == nil && rowsAffected > 0 {
+		log.Printf("Cleaned up %d old ticket actions", rowsAffected)
+	}
+
+	// Drop Slack message references whose notification record is gone
+	slackQuery := `
+		DELETE FROM slack_messages
+		WHERE NOT EXISTS (
+			SELECT 1 FROM notifications n
+			WHERE n.ticket_id = slack_messages.ticket_id
+				AND n.notification_type = slack_messages.notification_type
+		)
+	`
+
+	result, err = db.Exec(slackQuery)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err = result.RowsAffected()
+	if err == nil && rowsAffected > 0 {
+		log.Printf("Cleaned up %d old Slack message references", rowsAffected)
+	}
+
 	return nil
 }
 
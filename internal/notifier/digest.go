@@ -0,0 +1,183 @@
+package notifier
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/voicetel/freescout-notifier/internal/channel"
+	"github.com/voicetel/freescout-notifier/internal/config"
+	"github.com/voicetel/freescout-notifier/internal/database"
+	"github.com/voicetel/freescout-notifier/internal/models"
+)
+
+// digestTypes are the notification types listed in a digest
+var digestTypes = map[models.NotificationType]bool{
+	models.OpenNoAgentResponse:       true,
+	models.PendingNoCustomerResponse: true,
+}
+
+// digestTitle returns the title of a digest sent on the given schedule
+func digestTitle(schedule string) string {
+	switch schedule {
+	case config.DigestDaily:
+		return "Daily digest"
+	case config.DigestWeekly:
+		return "Weekly digest"
+	default:
+		return "Digest"
+	}
+}
+
+// digestEvent returns the business_hours_log event type recording the
+// digests of a schedule
+func digestEvent(schedule string) string {
+	if schedule == "" {
+		return "digest_sent"
+	}
+	return "digest_" + schedule
+}
+
+// digestDue reports whether the scheduled digest should be sent now: during
+// business hours, at least digest.after after they opened, and only once per
+// business day or week
+func (n *Notifier) digestDue(now time.Time) (bool, error) {
+	schedule := n.config.Digest.Schedule
+	if schedule == "" {
+		return false, nil
+	}
+
+	opened, ok := n.bizHours.OpenedAt(now)
+	if !ok || now.Before(opened.Add(n.config.Digest.After.Duration)) {
+		return false, nil
+	}
+
+	since := opened
+	if schedule == config.DigestWeekly {
+		since = n.bizHours.WeekStart(now)
+	}
+
+	last, err := n.localDB.LastBusinessHoursEvent(digestEvent(schedule))
+	if err != nil {
+		return false, err
+	}
+	return last.Before(since), nil
+}
+
+// SendDigest sends a digest of every overdue open and pending ticket now,
+// regardless of the schedule
+func (n *Notifier) SendDigest() error {
+	openTickets, err := database.GetOpenTicketsNeedingAttention(n.fsDB, n.openQuery())
+	if err != nil {
+		return fmt.Errorf("failed to get open tickets: %w", err)
+	}
+	pendingTickets, err := database.GetPendingTicketsNeedingAttention(n.fsDB, n.pendingQuery())
+	if err != nil {
+		return fmt.Errorf("failed to get pending tickets: %w", err)
+	}
+
+	tickets := append(openTickets, pendingTickets...)
+	for i := range tickets {
+		tickets[i].VIP = n.vip.Contains(tickets[i])
+	}
+	if err := database.LoadTags(n.fsDB, tickets); err != nil {
+		log.Printf("Error loading ticket tags: %v", err)
+	}
+	if err := n.localDB.LoadTicketOwners(tickets); err != nil {
+		log.Printf("Error loading ticket owners: %v", err)
+	}
+
+	return n.sendDigest(digestTitle(""), "", n.applyTags(tickets))
+}
+
+// sendDigest summarizes the overdue tickets, sends the summary to every
+// digest channel and records the send
+func (n *Notifier) sendDigest(title, schedule string, tickets []models.Ticket) error {
+	sentTimes := make(map[models.NotificationType]map[int]time.Time)
+	for notificationType := range digestTypes {
+		sent, err := n.localDB.GetSentTimes(notificationType)
+		if err != nil {
+			return fmt.Errorf("failed to load notification history: %w", err)
+		}
+		sentTimes[notificationType] = sent
+	}
+
+	var entries []channel.DigestTicket
+	for _, ticket := range tickets {
+		if _, excluded := n.excludedTag(ticket); excluded || !digestTypes[ticket.NotificationType] {
+			continue
+		}
+		ticket.EscalationTier = n.escalationTier(ticket)
+
+		entry := channel.DigestTicket{Notification: n.newNotification(ticket, models.StatusSent)}
+		if sentAt, ok := sentTimes[ticket.NotificationType][ticket.ID]; ok {
+			entry.LastAlertedAt = &sentAt
+		}
+		entries = append(entries, entry)
+	}
+
+	targets := n.digestTargets()
+	if len(targets) == 0 {
+		log.Printf("Warning: no configured channel supports digests")
+	}
+
+	delivered := 0
+	for _, t := range targets {
+		sender := t.Channel.(channel.DigestSender)
+
+		// Each channel only lists the notification types it handles
+		digest := channel.Digest{Title: title}
+		for _, entry := range entries {
+			if t.handles(entry.Notification) {
+				digest.Tickets = append(digest.Tickets, entry)
+			}
+		}
+
+		if n.config.DryRun {
+			log.Printf("Dry run: would send %s with %d tickets to channel %s", title, len(digest.Tickets), t.Name())
+			delivered++
+			continue
+		}
+		if err := sender.SendDigest(digest); err != nil {
+			log.Printf("Error sending digest to channel %s: %v", t.Name(), err)
+			continue
+		}
+		delivered++
+	}
+
+	if delivered == 0 && len(targets) > 0 {
+		return fmt.Errorf("no channel received the digest")
+	}
+
+	if err := n.localDB.LogBusinessHoursEvent(digestEvent(schedule), len(entries)); err != nil {
+		log.Printf("Warning: failed to log digest: %v", err)
+	}
+	if n.config.Verbose {
+		log.Printf("Sent %s with %d tickets to %d channels", title, len(entries), delivered)
+	}
+	return nil
+}
+
+// digestTargets returns the channels listed in digest.channels, or every
+// channel when none are listed, that support digests
+func (n *Notifier) digestTargets() []target {
+	names := make(map[string]bool, len(n.config.Digest.Channels))
+	for _, name := range n.config.Digest.Channels {
+		names[name] = true
+	}
+
+	var targets []target
+	for _, t := range n.channels {
+		if len(names) > 0 && !names[t.Name()] {
+			continue
+		}
+		if _, ok := t.Channel.(channel.DigestSender); !ok {
+			if n.config.Verbose {
+				log.Printf("Channel %s does not support digests", t.Name())
+			}
+			continue
+		}
+		targets = append(targets, t)
+	}
+	return targets
+}
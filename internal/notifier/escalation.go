@@ -0,0 +1,57 @@
+package notifier
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/voicetel/freescout-notifier/internal/config"
+	"github.com/voicetel/freescout-notifier/internal/models"
+)
+
+// escalationTier returns the 1-based escalation tier a ticket has reached,
+// or 0 when it is below the first tier or no escalation is configured
+func (n *Notifier) escalationTier(ticket models.Ticket) int {
+	waited := time.Duration(ticket.MinutesSinceReply) * time.Minute
+
+	reached := 0
+	for i, tier := range n.config.Escalation {
+		if waited >= tier.After.Duration && tierHandles(tier, ticket.NotificationType) {
+			reached = i + 1
+		}
+	}
+	return reached
+}
+
+// tier returns the configuration of a 1-based escalation tier, or nil when
+// the index is outside the ladder
+func (n *Notifier) tier(index int) *config.EscalationTier {
+	if index < 1 || index > len(n.config.Escalation) {
+		return nil
+	}
+	return &n.config.Escalation[index-1]
+}
+
+// tierName returns the display name of a 1-based escalation tier
+func (n *Notifier) tierName(index int) string {
+	tier := n.tier(index)
+	if tier == nil {
+		return ""
+	}
+	if tier.Name != "" {
+		return tier.Name
+	}
+	return fmt.Sprintf("Tier %d", index)
+}
+
+// tierHandles reports whether a tier applies to the notification type
+func tierHandles(tier config.EscalationTier, notificationType models.NotificationType) bool {
+	if len(tier.NotificationTypes) == 0 {
+		return true
+	}
+	for _, t := range tier.NotificationTypes {
+		if t == string(notificationType) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package notifier
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/voicetel/freescout-notifier/internal/config"
+)
+
+// HolidayRule is a holiday that recurs every year, on a fixed date such as
+// July 4, on the Nth or last weekday of a month such as the last Monday of
+// May, or relative to Easter such as Good Friday
+type HolidayRule struct {
+	Name     string `json:"name"`
+	Month    int    `json:"month"`    // 1-12
+	Day      int    `json:"day"`      // Fixed day of the month
+	Weekday  string `json:"weekday"`  // With Week, such as "monday"
+	Week     int    `json:"week"`     // 1-5 for the Nth weekday of the month, -1 for the last
+	Easter   *int   `json:"easter"`   // Days after Easter Sunday, negative for before
+	Observed bool   `json:"observed"` // Move a Saturday holiday to the Friday before and a Sunday one to the Monday after
+
+	weekday time.Weekday
+}
+
+// validate checks that the rule describes exactly one kind of date
+func (r *HolidayRule) validate() error {
+	if r.Easter != nil {
+		if r.Month != 0 || r.Day != 0 || r.Weekday != "" || r.Week != 0 {
+			return fmt.Errorf("easter cannot be combined with month, day, weekday or week")
+		}
+		return nil
+	}
+
+	if r.Month < 1 || r.Month > 12 {
+		return fmt.Errorf("month must be 1-12")
+	}
+	if r.Day != 0 {
+		if r.Weekday != "" || r.Week != 0 {
+			return fmt.Errorf("day cannot be combined with weekday or week")
+		}
+		if r.Day < 1 || r.Day > 31 {
+			return fmt.Errorf("day must be 1-31")
+		}
+		return nil
+	}
+
+	weekday, ok := config.ParseWeekday(r.Weekday)
+	if !ok {
+		return fmt.Errorf("needs a day, a weekday and week, or easter")
+	}
+	if r.Week != -1 && (r.Week < 1 || r.Week > 5) {
+		return fmt.Errorf("week must be 1-5, or -1 for the last %s of the month", weekday)
+	}
+	r.weekday = weekday
+	return nil
+}
+
+// Date returns the holiday in year, and false when it does not occur that
+// year, such as February 29 or a fifth Monday
+func (r HolidayRule) Date(year int) (time.Time, bool) {
+	month := time.Month(r.Month)
+
+	switch {
+	case r.Easter != nil:
+		return easter(year).AddDate(0, 0, *r.Easter), true
+
+	case r.Day != 0:
+		date := time.Date(year, month, r.Day, 0, 0, 0, 0, time.UTC)
+		return date, date.Month() == month
+
+	case r.Week == -1:
+		last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+		offset := (int(last.Weekday()) - int(r.weekday) + 7) % 7
+		return last.AddDate(0, 0, -offset), true
+
+	default:
+		first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		offset := (int(r.weekday) - int(first.Weekday()) + 7) % 7
+		date := first.AddDate(0, 0, offset+7*(r.Week-1))
+		return date, date.Month() == month
+	}
+}
+
+// On reports whether the holiday falls on the date of day, or for an
+// observed holiday whether it is observed on that date
+func (r HolidayRule) On(day time.Time) bool {
+	// The observed date of a holiday on January 1 or December 31 may fall
+	// in the neighbouring year
+	for year := day.Year() - 1; year <= day.Year()+1; year++ {
+		date, ok := r.Date(year)
+		if !ok {
+			continue
+		}
+		if r.Observed {
+			date = observedDate(date)
+		}
+		if sameDate(date, day) {
+			return true
+		}
+	}
+	return false
+}
+
+// observedDate moves a holiday on a Saturday to the Friday before and one on
+// a Sunday to the Monday after
+func observedDate(date time.Time) time.Time {
+	switch date.Weekday() {
+	case time.Saturday:
+		return date.AddDate(0, 0, -1)
+	case time.Sunday:
+		return date.AddDate(0, 0, 1)
+	default:
+		return date
+	}
+}
+
+// easter returns Easter Sunday of the Gregorian calendar in year
+func easter(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+func sameDate(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.Month() == b.Month() && a.Day() == b.Day()
+}
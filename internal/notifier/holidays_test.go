@@ -0,0 +1,174 @@
+package notifier
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mustRule parses and validates a holiday rule written as JSON
+func mustRule(t *testing.T, ruleJSON string) HolidayRule {
+	t.Helper()
+
+	var rule HolidayRule
+	if err := json.Unmarshal([]byte(ruleJSON), &rule); err != nil {
+		t.Fatalf("unmarshal rule: %v", err)
+	}
+	if err := rule.validate(); err != nil {
+		t.Fatalf("validate %s: %v", ruleJSON, err)
+	}
+	return rule
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestEaster(t *testing.T) {
+	tests := []time.Time{
+		date(2024, 3, 31),
+		date(2025, 4, 20),
+		date(2026, 4, 5),
+		date(2038, 4, 25), // Latest possible date
+		date(2285, 3, 22), // Earliest possible date
+	}
+
+	for _, want := range tests {
+		if got := easter(want.Year()); !got.Equal(want) {
+			t.Errorf("easter(%d) = %s, want %s", want.Year(), got.Format("2006-01-02"), want.Format("2006-01-02"))
+		}
+	}
+}
+
+func TestHolidayRuleDate(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   string
+		year   int
+		want   time.Time
+		wantOK bool
+	}{
+		// Fixed dates
+		{"fixed date", `{"month": 7, "day": 4}`, 2026, date(2026, 7, 4), true},
+		{"leap day in a leap year", `{"month": 2, "day": 29}`, 2028, date(2028, 2, 29), true},
+		{"leap day in another year", `{"month": 2, "day": 29}`, 2026, time.Time{}, false},
+
+		// Nth weekday of the month
+		{"third Monday of January", `{"month": 1, "weekday": "monday", "week": 3}`, 2025, date(2025, 1, 20), true},
+		{"first Monday on the 1st", `{"month": 9, "weekday": "mon", "week": 1}`, 2031, date(2031, 9, 1), true},
+		{"fourth Thursday of November", `{"month": 11, "weekday": "thursday", "week": 4}`, 2026, date(2026, 11, 26), true},
+		{"fifth Monday", `{"month": 3, "weekday": "monday", "week": 5}`, 2026, date(2026, 3, 30), true},
+		{"no fifth Monday", `{"month": 2, "weekday": "monday", "week": 5}`, 2026, time.Time{}, false},
+
+		// Last weekday of the month
+		{"last Monday of May 2025", `{"month": 5, "weekday": "monday", "week": -1}`, 2025, date(2025, 5, 26), true},
+		{"last Monday of May 2026", `{"month": 5, "weekday": "monday", "week": -1}`, 2026, date(2026, 5, 25), true},
+		{"last Monday of May on the 31st", `{"month": 5, "weekday": "monday", "week": -1}`, 2027, date(2027, 5, 31), true},
+		{"last Friday of December", `{"month": 12, "weekday": "friday", "week": -1}`, 2027, date(2027, 12, 31), true},
+
+		// Relative to Easter
+		{"Good Friday 2024", `{"easter": -2}`, 2024, date(2024, 3, 29), true},
+		{"Good Friday 2025", `{"easter": -2}`, 2025, date(2025, 4, 18), true},
+		{"Easter Monday 2038", `{"easter": 1}`, 2038, date(2038, 4, 26), true},
+		{"Easter Sunday", `{"easter": 0}`, 2026, date(2026, 4, 5), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := mustRule(t, tt.rule).Date(tt.year)
+			if ok != tt.wantOK {
+				t.Fatalf("Date(%d) ok = %t, want %t", tt.year, ok, tt.wantOK)
+			}
+			if ok && !got.Equal(tt.want) {
+				t.Errorf("Date(%d) = %s, want %s", tt.year, got.Format("2006-01-02"), tt.want.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func TestHolidayRuleOn(t *testing.T) {
+	const (
+		july4         = `{"month": 7, "day": 4}`
+		july4Observed = `{"month": 7, "day": 4, "observed": true}`
+		jan1          = `{"month": 1, "day": 1}`
+		jan1Observed  = `{"month": 1, "day": 1, "observed": true}`
+	)
+
+	tests := []struct {
+		name string
+		rule string
+		day  time.Time
+		want bool
+	}{
+		// July 4 on a Friday, Saturday and Sunday
+		{"observed on a weekday", july4Observed, date(2025, 7, 4), true},
+		{"Saturday moved to Friday", july4Observed, date(2026, 7, 3), true},
+		{"Saturday itself stays open", july4Observed, date(2026, 7, 4), false},
+		{"Sunday moved to Monday", july4Observed, date(2027, 7, 5), true},
+		{"Sunday itself stays open", july4Observed, date(2027, 7, 4), false},
+		{"not observed on Saturday", july4, date(2026, 7, 4), true},
+		{"not observed Friday before", july4, date(2026, 7, 3), false},
+
+		// January 1 on a Saturday is observed in the previous year
+		{"observed in the previous year", jan1Observed, date(2021, 12, 31), true},
+		{"observed in the previous year 2027", jan1Observed, date(2027, 12, 31), true},
+		{"Saturday January 1 stays open", jan1Observed, date(2022, 1, 1), false},
+		{"Sunday January 1 observed Monday", jan1Observed, date(2034, 1, 2), true},
+		{"not observed in the previous year", jan1, date(2027, 12, 31), false},
+		{"not observed on Saturday", jan1, date(2028, 1, 1), true},
+
+		// The time of day and time zone do not matter, only the date
+		{"time of day", july4, time.Date(2026, 7, 4, 23, 59, 0, 0, time.UTC), true},
+		{"local date", july4, time.Date(2026, 7, 4, 1, 0, 0, 0, time.FixedZone("UTC-6", -6*60*60)), true},
+
+		{"Nth weekday", `{"month": 11, "weekday": "thursday", "week": 4}`, date(2026, 11, 26), true},
+		{"Nth weekday a week early", `{"month": 11, "weekday": "thursday", "week": 4}`, date(2026, 11, 19), false},
+		{"Easter offset", `{"easter": -2}`, date(2038, 4, 23), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mustRule(t, tt.rule).On(tt.day); got != tt.want {
+				t.Errorf("On(%s) = %t, want %t", tt.day.Format("2006-01-02 Monday"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHolidayRuleValidate(t *testing.T) {
+	tests := []struct {
+		rule    string
+		wantErr string
+	}{
+		{`{"easter": -2, "month": 4}`, "easter cannot be combined"},
+		{`{"easter": 1, "weekday": "monday", "week": 1}`, "easter cannot be combined"},
+		{`{}`, "month must be 1-12"},
+		{`{"month": 13, "day": 1}`, "month must be 1-12"},
+		{`{"month": 7, "day": 4, "weekday": "friday"}`, "day cannot be combined"},
+		{`{"month": 7, "day": 32}`, "day must be 1-31"},
+		{`{"month": 7, "day": -1}`, "day must be 1-31"},
+		{`{"month": 7}`, "needs a day, a weekday and week, or easter"},
+		{`{"month": 7, "weekday": "someday", "week": 1}`, "needs a day, a weekday and week, or easter"},
+		{`{"month": 5, "weekday": "monday"}`, "week must be 1-5, or -1"},
+		{`{"month": 5, "weekday": "monday", "week": 6}`, "week must be 1-5, or -1"},
+		{`{"month": 5, "weekday": "monday", "week": -2}`, "week must be 1-5, or -1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			var rule HolidayRule
+			if err := json.Unmarshal([]byte(tt.rule), &rule); err != nil {
+				t.Fatalf("unmarshal rule: %v", err)
+			}
+
+			err := rule.validate()
+			if err == nil {
+				t.Fatalf("validate() = nil, want error containing %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validate() error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
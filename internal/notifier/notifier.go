@@ -1,70 +1,107 @@
 package notifier
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
 	"time"
 
+	"github.com/voicetel/freescout-notifier/internal/channel"
 	"github.com/voicetel/freescout-notifier/internal/config"
 	"github.com/voicetel/freescout-notifier/internal/database"
 	"github.com/voicetel/freescout-notifier/internal/models"
-	"github.com/voicetel/freescout-notifier/internal/slack"
+	"github.com/voicetel/freescout-notifier/internal/rules"
 )
 
 type Notifier struct {
 	fsDB     *sql.DB
 	localDB  *database.DB
 	config   *config.Config
-	slack    *slack.Client
+	channels []target
+	rules    *rules.Engine
+	vip      *VIPList
 	bizHours *BusinessHours
 }
 
-func New(fsDB *sql.DB, localDB *database.DB, cfg *config.Config) *Notifier {
+func New(fsDB *sql.DB, localDB *database.DB, cfg *config.Config) (*Notifier, error) {
+	channels, err := newTargets(cfg, localDB)
+	if err != nil {
+		return nil, err
+	}
+
+	engine, err := rules.New(cfg.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	bizHours, err := NewBusinessHours(cfg.BusinessHours)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Notifier{
 		fsDB:     fsDB,
 		localDB:  localDB,
 		config:   cfg,
-		slack:    slack.NewClient(cfg.Slack),
-		bizHours: NewBusinessHours(cfg.BusinessHours),
-	}
+		channels: channels,
+		rules:    engine,
+		vip:      NewVIPList(cfg.VIP),
+		bizHours: bizHours,
+	}, nil
 }
 
 func (n *Notifier) Run() (*models.RunStats, error) {
+	return n.RunContext(context.Background())
+}
+
+// RunContext checks tickets like Run. Once ctx is cancelled no further
+// tickets are processed, but sends already in flight are completed; the
+// remaining tickets are picked up by the next run.
+func (n *Notifier) RunContext(ctx context.Context) (*models.RunStats, error) {
 	start := time.Now()
 	stats := &models.RunStats{}
 
 	now := time.Now()
 	isBusinessHours := n.bizHours.IsBusinessHours(now)
-	isStartOfDay := n.bizHours.IsStartOfBusinessDay(now)
+	flushQueue, err := n.trackBusinessHours(isBusinessHours)
+	if err != nil {
+		log.Printf("Error tracking business hours: %v", err)
+		stats.Errors++
+	}
 
 	if n.config.Verbose {
 		log.Printf("Current time: %s", now.Format("2006-01-02 15:04:05"))
 		log.Printf("Is business hours: %t", isBusinessHours)
-		log.Printf("Is start of day: %t", isStartOfDay)
+		log.Printf("Flushing queue: %t", flushQueue)
 	}
 
-	// If start of business day, process queued notifications first
-	if isStartOfDay {
-		sent, err := n.sendQueuedNotifications()
+	// On the first runs after business hours open, process queued
+	// notifications first
+	if flushQueue {
+		sent, err := n.sendQueuedNotifications(ctx)
 		if err != nil {
 			log.Printf("Error sending queued notifications: %v", err)
 			stats.Errors++
 		} else {
 			stats.NotificationsSent += sent
+			if err := n.markQueueFlushed(); err != nil {
+				log.Printf("Error recording business hours opening: %v", err)
+				stats.Errors++
+			}
 		}
 	}
 
 	// Get open tickets needing attention
-	openTickets, err := database.GetOpenTicketsNeedingAttention(n.fsDB, n.config.OpenThreshold)
+	openTickets, err := database.GetOpenTicketsNeedingAttention(n.fsDB, n.openQuery())
 	if err != nil {
 		return stats, fmt.Errorf("failed to get open tickets: %w", err)
 	}
 	stats.TicketsChecked += len(openTickets)
 
 	// Get pending tickets needing attention
-	pendingTickets, err := database.GetPendingTicketsNeedingAttention(n.fsDB, n.config.PendingThreshold)
+	pendingTickets, err := database.GetPendingTicketsNeedingAttention(n.fsDB, n.pendingQuery())
 	if err != nil {
 		return stats, fmt.Errorf("failed to get pending tickets: %w", err)
 	}
@@ -72,8 +109,73 @@ func (n *Notifier) Run() (*models.RunStats, error) {
 
 	// Process all tickets
 	allTickets := append(openTickets, pendingTickets...)
+	checkedTypes := []models.NotificationType{models.OpenNoAgentResponse, models.PendingNoCustomerResponse}
+
+	// Get unassigned conversations needing attention
+	if n.config.UnassignedThreshold.Duration > 0 {
+		unassignedTickets, err := database.GetUnassignedTicketsNeedingAttention(n.fsDB, n.ticketQuery(n.config.UnassignedThreshold,
+			func(r config.MailboxRules) config.Duration { return r.UnassignedThreshold }, config.Duration{}))
+		if err != nil {
+			return stats, fmt.Errorf("failed to get unassigned tickets: %w", err)
+		}
+		stats.TicketsChecked += len(unassignedTickets)
+
+		allTickets = append(allTickets, unassignedTickets...)
+		checkedTypes = append(checkedTypes, models.UnassignedConversation)
+	}
+
+	// Get pending tickets the customer has replied to without an answer
+	if n.config.ReturnedThreshold.Duration > 0 {
+		returnedTickets, err := n.findReturnedTickets()
+		if err != nil {
+			return stats, fmt.Errorf("failed to get returned tickets: %w", err)
+		}
+		stats.TicketsChecked += len(returnedTickets)
+
+		allTickets = append(allTickets, returnedTickets...)
+		checkedTypes = append(checkedTypes, models.ReturnedFromPending)
+	}
+
+	for i := range allTickets {
+		allTickets[i].VIP = n.vip.Contains(allTickets[i])
+	}
+
+	if err := database.LoadTags(n.fsDB, allTickets); err != nil {
+		log.Printf("Error loading ticket tags: %v", err)
+		stats.Errors++
+	}
+	allTickets = n.applyTags(allTickets)
+
+	if err := n.localDB.LoadTicketOwners(allTickets); err != nil {
+		log.Printf("Error loading ticket owners: %v", err)
+		stats.Errors++
+	}
+
+	// Resolve alerts for tickets that no longer need attention
+	resolved, err := n.resolveAnsweredTickets(allTickets, checkedTypes)
+	if err != nil {
+		log.Printf("Error resolving answered tickets: %v", err)
+		stats.Errors++
+	}
+	stats.NotificationsResolved += resolved
+
+	// Send the scheduled digest once business hours have been open long enough
+	due, err := n.digestDue(now)
+	if err != nil {
+		log.Printf("Error checking digest schedule: %v", err)
+		stats.Errors++
+	} else if due {
+		if err := n.sendDigest(digestTitle(n.config.Digest.Schedule), n.config.Digest.Schedule, allTickets); err != nil {
+			log.Printf("Error sending digest: %v", err)
+			stats.Errors++
+		}
+	}
 
-	for _, ticket := range allTickets {
+	for i, ticket := range allTickets {
+		if ctx.Err() != nil {
+			log.Printf("Stopping run, %d tickets left for the next run", len(allTickets)-i)
+			break
+		}
 		if err := n.processTicket(ticket, isBusinessHours, stats); err != nil {
 			log.Printf("Error processing ticket %d: %v", ticket.ID, err)
 			stats.Errors++
@@ -85,6 +187,30 @@ func (n *Notifier) Run() (*models.RunStats, error) {
 }
 
 func (n *Notifier) processTicket(ticket models.Ticket, isBusinessHours bool, stats *models.RunStats) error {
+	ticket.EscalationTier = n.escalationTier(ticket)
+
+	if tag, ok := n.excludedTag(ticket); ok {
+		if n.config.Verbose {
+			log.Printf("Skipping ticket #%d tagged %q", ticket.Number, tag)
+		}
+		// A notification queued before the tag was added is not delivered
+		return n.markExcluded(ticket, models.StatusQueued)
+	}
+
+	if rule := n.rules.Match(ticket); rule != nil {
+		switch rule.Action {
+		case rules.ActionSuppress:
+			if n.config.Verbose {
+				log.Printf("Ticket #%d suppressed by rule %s", ticket.Number, rule.Name)
+			}
+			return nil
+		case rules.ActionEscalate:
+			if rule.Tier > ticket.EscalationTier {
+				ticket.EscalationTier = rule.Tier
+			}
+		}
+	}
+
 	// Check if we should skip this ticket
 	shouldSkip, err := n.shouldSkipTicket(ticket)
 	if err != nil {
@@ -94,8 +220,9 @@ func (n *Notifier) processTicket(ticket models.Ticket, isBusinessHours bool, sta
 		return nil
 	}
 
-	if isBusinessHours {
-		// Send immediately
+	if isBusinessHours || ticket.VIP || ticket.Priority {
+		// Send immediately; VIP and priority tickets are never held outside
+		// business hours
 		if !n.config.DryRun {
 			if err := n.sendNotification(ticket); err != nil {
 				return err
@@ -125,18 +252,28 @@ func (n *Notifier) processTicket(ticket models.Ticket, isBusinessHours bool, sta
 }
 
 func (n *Notifier) shouldSkipTicket(ticket models.Ticket) (bool, error) {
+	// Honor acknowledgements and snoozes from Slack
+	suppressed, err := n.localDB.IsTicketSuppressed(ticket.ID, ticket.LastReplyAt, time.Now())
+	if err != nil {
+		return false, err
+	}
+	if suppressed {
+		return true, nil
+	}
+
 	query := `
-		SELECT sent_at, notification_status
+		SELECT sent_at, notification_status, escalation_tier, last_reply_at
 		FROM notifications
 		WHERE ticket_id = ? AND notification_type = ?
 		ORDER BY COALESCE(sent_at, queued_at, first_eligible_at) DESC
 		LIMIT 1
 	`
 
-	var sentAt sql.NullTime
+	var sentAt, lastReplyAt sql.NullTime
 	var status string
+	var lastTier int
 
-	err := n.localDB.QueryRow(query, ticket.ID, ticket.NotificationType).Scan(&sentAt, &status)
+	err = n.localDB.QueryRow(query, ticket.ID, ticket.NotificationType).Scan(&sentAt, &status, &lastTier, &lastReplyAt)
 	if err == sql.ErrNoRows {
 		return false, nil // No previous notification
 	}
@@ -144,14 +281,24 @@ func (n *Notifier) shouldSkipTicket(ticket models.Ticket) (bool, error) {
 		return false, err
 	}
 
-	// If already queued, skip
+	// A new reply restarts the escalation ladder; reaching a higher tier
+	// bypasses the cooldown
+	if !lastReplyAt.Valid || !lastReplyAt.Time.Equal(ticket.LastReplyAt) {
+		lastTier = 0
+	}
+	escalated := ticket.EscalationTier > lastTier
+
+	// If already queued, skip unless the queued notification is escalating
 	if status == string(models.StatusQueued) {
-		return true, nil
+		return !escalated, nil
+	}
+	if escalated {
+		return false, nil
 	}
 
 	// Check cooldown
 	if sentAt.Valid {
-		cooldownExpiry := sentAt.Time.Add(n.config.CooldownPeriod.Duration)
+		cooldownExpiry := sentAt.Time.Add(n.config.CooldownFor(ticket.MailboxID))
 		if time.Now().Before(cooldownExpiry) {
 			return true, nil // Still in cooldown
 		}
@@ -176,11 +323,13 @@ func (n *Notifier) recordNotification(ticket models.Ticket, status models.Notifi
 			assigned_user,
 			minutes_waiting,
 			threshold_minutes,
+			escalation_tier,
+			last_reply_at,
 			ticket_data,
 			queued_at,
 			sent_at
 		)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(ticket_id, notification_type)
 		DO UPDATE SET
 			notification_status = excluded.notification_status,
@@ -188,6 +337,9 @@ func (n *Notifier) recordNotification(ticket models.Ticket, status models.Notifi
 			customer_name = excluded.customer_name,
 			assigned_user = excluded.assigned_user,
 			minutes_waiting = excluded.minutes_waiting,
+			threshold_minutes = excluded.threshold_minutes,
+			escalation_tier = excluded.escalation_tier,
+			last_reply_at = excluded.last_reply_at,
 			ticket_data = excluded.ticket_data,
 			queued_at = CASE
 				WHEN excluded.notification_status = 'queued' THEN CURRENT_TIMESTAMP
@@ -199,6 +351,8 @@ func (n *Notifier) recordNotification(ticket models.Ticket, status models.Notifi
 			END
 		WHERE notifications.sent_at IS NULL
 			OR notifications.sent_at < datetime('now', '-' || ? || ' seconds')
+			OR excluded.escalation_tier > notifications.escalation_tier
+			OR notifications.last_reply_at IS NOT excluded.last_reply_at
 	`
 
 	var queuedAt, sentAt sql.NullTime
@@ -208,10 +362,7 @@ func (n *Notifier) recordNotification(ticket models.Ticket, status models.Notifi
 		sentAt = sql.NullTime{Time: time.Now(), Valid: true}
 	}
 
-	thresholdMinutes := int(n.config.OpenThreshold.Duration.Minutes())
-	if ticket.NotificationType == models.PendingNoCustomerResponse {
-		thresholdMinutes = int(n.config.PendingThreshold.Duration.Minutes())
-	}
+	thresholdMinutes := int(n.thresholdFor(ticket).Minutes())
 
 	_, err = n.localDB.Exec(query,
 		ticket.ID,
@@ -222,51 +373,150 @@ func (n *Notifier) recordNotification(ticket models.Ticket, status models.Notifi
 		ticket.AssignedUserName,
 		ticket.MinutesSinceReply,
 		thresholdMinutes,
+		ticket.EscalationTier,
+		ticket.LastReplyAt,
 		string(ticketJSON),
 		queuedAt,
 		sentAt,
-		int(n.config.CooldownPeriod.Duration.Seconds()),
+		int(n.config.CooldownFor(ticket.MailboxID).Seconds()),
 	)
 
 	return err
 }
 
-func (n *Notifier) sendNotification(ticket models.Ticket) error {
-	message := n.formatSlackMessage(ticket)
-	return n.slack.SendMessage(message)
+// openQuery selects open tickets waiting on an agent past their threshold
+func (n *Notifier) openQuery() database.TicketQuery {
+	return n.ticketQuery(n.config.OpenThreshold,
+		func(r config.MailboxRules) config.Duration { return r.OpenThreshold }, n.config.VIP.OpenThreshold)
 }
 
-func (n *Notifier) formatSlackMessage(ticket models.Ticket) string {
-	emoji := "🚨"
-	action := "needs attention"
-	waitingFor := "agent response"
+// pendingQuery selects pending tickets waiting on the customer past their threshold
+func (n *Notifier) pendingQuery() database.TicketQuery {
+	return n.ticketQuery(n.config.PendingThreshold,
+		func(r config.MailboxRules) config.Duration { return r.PendingThreshold }, n.config.VIP.PendingThreshold)
+}
 
-	if ticket.NotificationType == models.PendingNoCustomerResponse {
-		emoji = "⏳"
-		action = "waiting for customer"
-		waitingFor = "customer response"
+// ticketQuery builds a FreeScout query from a global threshold, the
+// per-mailbox overrides selected by threshold and the VIP threshold
+func (n *Notifier) ticketQuery(global config.Duration, threshold func(config.MailboxRules) config.Duration, vip config.Duration) database.TicketQuery {
+	q := database.TicketQuery{Threshold: global.Duration, Filter: Filter(n.config)}
+	if vip.Duration > 0 {
+		q.VIP = n.vip.Customers()
+		q.VIPThreshold = vip.Duration
 	}
+	for id, rules := range n.config.Mailboxes {
+		if d := threshold(rules).Duration; d > 0 {
+			if q.MailboxThresholds == nil {
+				q.MailboxThresholds = make(map[int]time.Duration)
+			}
+			q.MailboxThresholds[id] = d
+		}
+	}
+	return q
+}
 
-	timeAgo := formatDuration(time.Duration(ticket.MinutesSinceReply) * time.Minute)
-	ticketURL := fmt.Sprintf("%s/conversation/%d", n.config.FreeScout.URL, ticket.ID)
+// Filter returns the mailbox and assignee filter of the configuration
+func Filter(cfg *config.Config) database.Filter {
+	return database.Filter{
+		IncludeMailboxes: cfg.Filters.IncludeMailboxes,
+		ExcludeMailboxes: cfg.Filters.ExcludeMailboxes,
+		IncludeUsers:     cfg.Filters.IncludeUsers,
+		ExcludeUsers:     cfg.Filters.ExcludeUsers,
+	}
+}
 
-	message := fmt.Sprintf("%s Ticket #%d %s\n", emoji, ticket.ID, action)
-	message += fmt.Sprintf("*Subject:* %s\n", ticket.Subject)
-	message += fmt.Sprintf("*Customer:* %s\n", ticket.CustomerName)
-	message += fmt.Sprintf("*Waiting for:* %s for %s\n", waitingFor, timeAgo)
+// thresholdFor returns the threshold that applied to a ticket, matching the
+// thresholds used by the FreeScout queries
+func (n *Notifier) thresholdFor(ticket models.Ticket) time.Duration {
+	var threshold, vipThreshold time.Duration
+	switch ticket.NotificationType {
+	case models.PendingNoCustomerResponse:
+		threshold, vipThreshold = n.config.PendingThresholdFor(ticket.MailboxID), n.config.VIP.PendingThreshold.Duration
+	case models.UnassignedConversation:
+		threshold = n.config.UnassignedThresholdFor(ticket.MailboxID)
+	case models.ReturnedFromPending:
+		threshold = n.config.ReturnedThresholdFor(ticket.MailboxID)
+	default:
+		threshold, vipThreshold = n.config.OpenThresholdFor(ticket.MailboxID), n.config.VIP.OpenThreshold.Duration
+	}
 
-	if ticket.AssignedUserName != "" {
-		message += fmt.Sprintf("*Assigned to:* %s\n", ticket.AssignedUserName)
-	} else {
-		message += "*Assigned to:* Unassigned\n"
+	// VIP thresholds only ever make a ticket alert sooner
+	if ticket.VIP && vipThreshold > 0 && vipThreshold < threshold {
+		return vipThreshold
 	}
+	return threshold
+}
 
-	message += fmt.Sprintf("*View ticket:* <%s|Open in FreeScout>", ticketURL)
+func (n *Notifier) sendNotification(ticket models.Ticket) error {
+	return n.dispatch(n.newNotification(ticket, models.StatusSent))
+}
 
-	return message
+// newNotification wraps a ticket for delivery to the notification channels
+func (n *Notifier) newNotification(ticket models.Ticket, status models.NotificationStatus) channel.Notification {
+	notification := channel.Notification{
+		Ticket:    ticket,
+		Status:    status,
+		TicketURL: fmt.Sprintf("%s/conversation/%d", n.config.FreeScout.URL, ticket.ID),
+	}
+	if tier := n.tier(ticket.EscalationTier); tier != nil {
+		notification.TierName = n.tierName(ticket.EscalationTier)
+		notification.Tone = models.Tone(tier.Tone)
+	}
+	return notification
 }
 
-func (n *Notifier) sendQueuedNotifications() (int, error) {
+// Business hours state changes recorded in business_hours_log
+const (
+	eventHoursOpened = "hours_opened"
+	eventHoursClosed = "hours_closed"
+)
+
+// trackBusinessHours records when business hours are observed closed and
+// reports whether the queue should be flushed: on every run inside business
+// hours after a closed period, until the queue has been emptied. Unlike a
+// fixed window at the start hour, this survives late or missed runs.
+func (n *Notifier) trackBusinessHours(isBusinessHours bool) (bool, error) {
+	state, err := n.localDB.LastBusinessHoursState(eventHoursOpened, eventHoursClosed)
+	if err != nil {
+		return false, err
+	}
+
+	if !isBusinessHours {
+		if state != eventHoursClosed {
+			return false, n.localDB.LogBusinessHoursEvent(eventHoursClosed, 0)
+		}
+		return false, nil
+	}
+
+	return state != eventHoursOpened && n.bizHours.notifyOnOpen, nil
+}
+
+// markQueueFlushed records that business hours are open once every queued
+// notification has been sent, so later runs stop flushing until the next
+// closed period. Rows left over by the per-run limit or failed sends keep
+// the flush going on the next run.
+func (n *Notifier) markQueueFlushed() error {
+	queued, err := n.localDB.CountQueuedNotifications()
+	if err != nil {
+		return err
+	}
+	if queued > 0 {
+		if n.config.Verbose {
+			log.Printf("%d queued notifications left for the next run", queued)
+		}
+		return nil
+	}
+	return n.localDB.LogBusinessHoursEvent(eventHoursOpened, 0)
+}
+
+// queuedNotification is a notification row held until business hours start
+type queuedNotification struct {
+	ticketID         int
+	notificationType string
+	notification     channel.Notification
+}
+
+func (n *Notifier) loadQueuedNotifications() ([]queuedNotification, error) {
 	query := `
 		SELECT
 			ticket_id,
@@ -280,17 +530,16 @@ func (n *Notifier) sendQueuedNotifications() (int, error) {
 
 	rows, err := n.localDB.Query(query, n.config.MaxNotifications)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	defer rows.Close()
 
-	sent := 0
+	var queued []queuedNotification
 	for rows.Next() {
-		var ticketID int
-		var notificationType string
+		var q queuedNotification
 		var ticketData string
 
-		if err := rows.Scan(&ticketID, &notificationType, &ticketData); err != nil {
+		if err := rows.Scan(&q.ticketID, &q.notificationType, &ticketData); err != nil {
 			log.Printf("Error scanning queued notification: %v", err)
 			continue
 		}
@@ -301,12 +550,102 @@ func (n *Notifier) sendQueuedNotifications() (int, error) {
 			continue
 		}
 
-		// Send notification
-		if !n.config.DryRun {
-			if err := n.sendNotification(ticket); err != nil {
-				log.Printf("Error sending queued notification for ticket %d: %v", ticketID, err)
+		q.notification = n.newNotification(ticket, models.StatusQueued)
+		queued = append(queued, q)
+	}
+
+	return queued, rows.Err()
+}
+
+func (n *Notifier) sendQueuedNotifications(ctx context.Context) (int, error) {
+	queued, err := n.loadQueuedNotifications()
+	if err != nil {
+		return 0, err
+	}
+	if len(queued) == 0 {
+		return 0, nil
+	}
+
+	// Tickets that no channel is configured to receive count as delivered
+	// so they leave the queue
+	delivered := make([]bool, len(queued))
+	for i, q := range queued {
+		delivered[i] = !n.deliversAny(n.channels, q.notification)
+	}
+
+	// Channels configured for batching, or every channel when batching is
+	// enabled globally, receive the whole burst as grouped messages; the
+	// rest get one message per ticket
+	var individual []target
+	for _, t := range n.channels {
+		batcher, ok := t.Channel.(channel.BatchSender)
+		if !ok || !(t.cfg.BatchQueued || n.config.BusinessHours.BatchQueued) {
+			individual = append(individual, t)
+			continue
+		}
+
+		var batch []channel.Notification
+		var indexes []int
+		for i, q := range queued {
+			if n.delivers(t, q.notification) {
+				batch = append(batch, q.notification)
+				indexes = append(indexes, i)
+			}
+		}
+		if len(batch) == 0 {
+			continue
+		}
+
+		if n.config.DryRun {
+			for _, i := range indexes {
+				delivered[i] = true
+			}
+			continue
+		}
+
+		// Tickets in the messages that failed stay queued unless another
+		// channel delivers them
+		sent, err := batcher.SendBatch(batch)
+		if err != nil {
+			log.Printf("Error sending queued batch to channel %s: %v", t.Name(), err)
+		}
+		for j, i := range indexes {
+			if sent[j] {
+				delivered[i] = true
+			}
+		}
+	}
+
+	if len(individual) > 0 {
+		for i, q := range queued {
+			if ctx.Err() != nil {
+				break
+			}
+			if !n.deliversAny(individual, q.notification) {
 				continue
 			}
+			if !n.config.DryRun {
+				if err := n.sendTo(individual, q.notification); err != nil {
+					log.Printf("Error sending queued notification for ticket %d: %v", q.ticketID, err)
+					continue
+				}
+			}
+			delivered[i] = true
+
+			// Rate limit
+			if i < len(queued)-1 {
+				select {
+				case <-ctx.Done():
+				case <-time.After(2 * time.Second):
+				}
+			}
+		}
+	}
+
+	sent := 0
+	for i, q := range queued {
+		if !delivered[i] {
+			continue
 		}
 
 		// Update status
@@ -315,55 +654,20 @@ func (n *Notifier) sendQueuedNotifications() (int, error) {
 			SET notification_status = 'sent', sent_at = CURRENT_TIMESTAMP
 			WHERE ticket_id = ? AND notification_type = ?
 		`
-		if _, err := n.localDB.Exec(updateQuery, ticketID, notificationType); err != nil {
+		if _, err := n.localDB.Exec(updateQuery, q.ticketID, q.notificationType); err != nil {
 			log.Printf("Error updating notification status: %v", err)
 			continue
 		}
 
 		sent++
-
-		// Rate limit
-		if sent < n.config.MaxNotifications {
-			time.Sleep(2 * time.Second)
-		}
 	}
 
 	// Log business hours event
 	if sent > 0 {
-		logQuery := `
-			INSERT INTO business_hours_log (event_type, notifications_sent)
-			VALUES ('burst_sent', ?)
-		`
-		if _, err := n.localDB.Exec(logQuery, sent); err != nil {
+		if err := n.localDB.LogBusinessHoursEvent("burst_sent", sent); err != nil {
 			log.Printf("Warning: failed to log business hours event: %v", err)
 		}
 	}
 
 	return sent, nil
 }
-
-func formatDuration(d time.Duration) string {
-	if d < time.Hour {
-		return fmt.Sprintf("%d minutes", int(d.Minutes()))
-	}
-
-	hours := int(d.Hours())
-	minutes := int(d.Minutes()) % 60
-
-	if hours == 1 {
-		if minutes == 0 {
-			return "1 hour"
-		}
-		return fmt.Sprintf("1 hour %d minutes", minutes)
-	}
-
-	if minutes == 0 {
-		return fmt.Sprintf("%d hours", hours)
-	}
-	return fmt.Sprintf("%d hours %d minutes", hours, minutes)
-}
-
-func TestSlackWebhook(cfg config.SlackConfig) error {
-	client := slack.NewClient(cfg)
-	return client.SendMessage("🔧 FreeScout Notifier test message - connection successful!")
-}
@@ -0,0 +1,159 @@
+package notifier
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/voicetel/freescout-notifier/internal/models"
+)
+
+// ticketKey identifies a notification record
+type ticketKey struct {
+	ticketID         int
+	notificationType models.NotificationType
+}
+
+// answeredTicket is a ticket that no longer needs attention, with the
+// channels that received its alerts
+type answeredTicket struct {
+	models.Ticket
+	alerted []target
+}
+
+// resolveAnsweredTickets finds sent or queued notifications whose tickets are
+// no longer returned by the FreeScout queries for the given types, resolves
+// their alerts on the channels that support it and marks the records
+// resolved so queued alerts for answered tickets are not delivered later
+func (n *Notifier) resolveAnsweredTickets(current []models.Ticket, types []models.NotificationType) (int, error) {
+	if len(types) == 0 {
+		return 0, nil
+	}
+
+	stillOpen := make(map[ticketKey]bool, len(current))
+	for _, ticket := range current {
+		stillOpen[ticketKey{ticket.ID, ticket.NotificationType}] = true
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(types)), ",")
+	query := `
+		SELECT ticket_id, notification_type, notification_status, ticket_data, minutes_waiting, sent_at
+		FROM notifications
+		WHERE notification_status IN ('sent', 'queued')
+			AND notification_type IN (` + placeholders + `)
+	`
+
+	args := make([]interface{}, len(types))
+	for i, t := range types {
+		args[i] = t
+	}
+
+	rows, err := n.localDB.Query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	filter := Filter(n.config)
+
+	var answered []answeredTicket
+	var excluded []models.Ticket
+	for rows.Next() {
+		var key ticketKey
+		var status models.NotificationStatus
+		var ticketData string
+		var minutesWaiting sql.NullInt64
+		var sentAt sql.NullTime
+		if err := rows.Scan(&key.ticketID, &key.notificationType, &status, &ticketData, &minutesWaiting, &sentAt); err != nil {
+			log.Printf("Error scanning sent notification: %v", err)
+			continue
+		}
+		if stillOpen[key] {
+			continue
+		}
+
+		var ticket models.Ticket
+		if err := json.Unmarshal([]byte(ticketData), &ticket); err != nil {
+			log.Printf("Error unmarshaling ticket data: %v", err)
+			continue
+		}
+
+		// Tickets the mailbox and assignee filters now leave out were not
+		// answered, so their alerts are left unchanged
+		if !filter.Matches(ticket) {
+			excluded = append(excluded, ticket)
+			continue
+		}
+
+		// Only channels that received an alert are told it was answered,
+		// routed by the ticket as it was at the last alert
+		var alerted []target
+		if status == models.StatusSent {
+			alerted = n.alertedTargets(ticket)
+		}
+
+		// Estimate how long the ticket had been waiting when it was answered
+		// from the wait recorded at the last alert
+		if minutesWaiting.Valid && sentAt.Valid {
+			ticket.MinutesSinceReply = int(minutesWaiting.Int64) + int(time.Since(sentAt.Time).Minutes())
+		}
+
+		answered = append(answered, answeredTicket{Ticket: ticket, alerted: alerted})
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+
+	for _, ticket := range excluded {
+		if err := n.markExcluded(ticket, models.StatusSent, models.StatusQueued); err != nil {
+			log.Printf("Error updating notification status: %v", err)
+		}
+	}
+
+	resolved := 0
+	for _, ticket := range answered {
+		if !n.config.DryRun {
+			resolveWith(ticket.alerted, n.newNotification(ticket.Ticket, models.StatusResolved))
+		}
+
+		updateQuery := `
+			UPDATE notifications
+			SET notification_status = 'resolved'
+			WHERE ticket_id = ? AND notification_type = ?
+				AND notification_status IN ('sent', 'queued')
+		`
+		if _, err := n.localDB.Exec(updateQuery, ticket.ID, ticket.NotificationType); err != nil {
+			log.Printf("Error updating notification status: %v", err)
+			continue
+		}
+
+		resolved++
+
+		if n.config.Verbose {
+			log.Printf("Resolved notification for ticket #%d", ticket.Number)
+		}
+	}
+
+	return resolved, nil
+}
+
+// markExcluded closes the notification records of a ticket in the given
+// statuses without resolving its alerts, for tickets skipped by a filter
+func (n *Notifier) markExcluded(ticket models.Ticket, statuses ...models.NotificationStatus) error {
+	args := []interface{}{models.StatusExcluded, ticket.ID, ticket.NotificationType}
+	for _, status := range statuses {
+		args = append(args, status)
+	}
+
+	query := `
+		UPDATE notifications
+		SET notification_status = ?
+		WHERE ticket_id = ? AND notification_type = ?
+			AND notification_status IN (` + strings.TrimSuffix(strings.Repeat("?,", len(statuses)), ",") + `)
+	`
+	_, err := n.localDB.Exec(query, args...)
+	return err
+}
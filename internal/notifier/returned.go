@@ -0,0 +1,69 @@
+package notifier
+
+import (
+	"time"
+
+	"github.com/voicetel/freescout-notifier/internal/database"
+	"github.com/voicetel/freescout-notifier/internal/models"
+)
+
+// findReturnedTickets remembers which conversations are pending and returns
+// those that were pending on an earlier run and have since received a
+// customer reply that is still unanswered after the threshold
+func (n *Notifier) findReturnedTickets() ([]models.Ticket, error) {
+	previous, err := n.localDB.GetPendingConversations()
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := database.GetPendingConversationIDs(n.fsDB, Filter(n.config))
+	if err != nil {
+		return nil, err
+	}
+
+	stillPending := make(map[int]bool, len(current))
+	for _, id := range current {
+		stillPending[id] = true
+	}
+
+	var left []int
+	for _, id := range previous {
+		if !stillPending[id] {
+			left = append(left, id)
+		}
+	}
+
+	candidates, err := database.GetReturnedTickets(n.fsDB, left, Filter(n.config))
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep tracking tickets waiting on an agent after the customer came
+	// back; forget the ones that were answered, closed or deleted
+	waiting := make(map[int]bool, len(candidates))
+	for _, ticket := range candidates {
+		waiting[ticket.ID] = true
+	}
+	var forget []int
+	for _, id := range left {
+		if !waiting[id] {
+			forget = append(forget, id)
+		}
+	}
+
+	if err := n.localDB.SavePendingConversations(current, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := n.localDB.ForgetPendingConversations(forget); err != nil {
+		return nil, err
+	}
+
+	var returned []models.Ticket
+	for _, ticket := range candidates {
+		threshold := n.config.ReturnedThresholdFor(ticket.MailboxID)
+		if time.Duration(ticket.MinutesSinceReply)*time.Minute >= threshold {
+			returned = append(returned, ticket)
+		}
+	}
+	return returned, nil
+}
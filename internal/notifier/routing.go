@@ -0,0 +1,75 @@
+package notifier
+
+import (
+	"github.com/voicetel/freescout-notifier/internal/channel"
+	"github.com/voicetel/freescout-notifier/internal/rules"
+)
+
+// routes returns the names of the channels a notification is routed to, or
+// nil when it goes to every channel. A matching notify rule takes precedence
+// over escalation tiers with their own channels, then tag routes, then the
+// mailbox routes.
+func (n *Notifier) routes(notification channel.Notification) []string {
+	if rule := n.rules.Match(notification.Ticket); rule != nil && rule.Action == rules.ActionNotify {
+		return rule.Channels
+	}
+	if tier := n.tier(notification.Ticket.EscalationTier); tier != nil && len(tier.Channels) > 0 {
+		return tier.Channels
+	}
+
+	routing := n.config.Routing
+	if channels := tagRoutes(routing.Tags, notification.Ticket.Tags); len(channels) > 0 {
+		return channels
+	}
+	if channels, ok := routing.Mailboxes[notification.Ticket.MailboxID]; ok {
+		return channels
+	}
+	return routing.Default
+}
+
+// delivers reports whether a notification is routed to the target and
+// passes the target's filters
+func (n *Notifier) delivers(t target, notification channel.Notification) bool {
+	if !t.accepts(notification) {
+		return false
+	}
+
+	routes := n.routes(notification)
+	if len(routes) == 0 {
+		return true
+	}
+	for _, name := range routes {
+		if name == t.Name() {
+			return true
+		}
+	}
+	return false
+}
+
+// deliversAny reports whether any of the targets would receive the notification
+func (n *Notifier) deliversAny(targets []target, notification channel.Notification) bool {
+	for _, t := range targets {
+		if n.delivers(t, notification) {
+			return true
+		}
+	}
+	return false
+}
+
+// tagRoutes returns the channels routed to by any of the ticket's tags
+func tagRoutes(routes map[string][]string, tags []string) []string {
+	var channels []string
+	seen := make(map[string]bool)
+	for route, names := range routes {
+		if !hasTag(tags, route) {
+			continue
+		}
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				channels = append(channels, name)
+			}
+		}
+	}
+	return channels
+}
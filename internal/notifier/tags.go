@@ -0,0 +1,47 @@
+package notifier
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/voicetel/freescout-notifier/internal/models"
+)
+
+// applyTags flags tickets with a priority tag, moving them to the front so
+// they are alerted first. Tickets with an excluded tag are kept so that their
+// alerts are not resolved as answered; processTicket skips them.
+func (n *Notifier) applyTags(tickets []models.Ticket) []models.Ticket {
+	for i := range tickets {
+		_, tickets[i].Priority = firstTag(tickets[i].Tags, n.config.Tags.Priority)
+	}
+
+	sort.SliceStable(tickets, func(i, j int) bool {
+		return tickets[i].Priority && !tickets[j].Priority
+	})
+	return tickets
+}
+
+// excludedTag returns the first excluded tag the ticket has
+func (n *Notifier) excludedTag(ticket models.Ticket) (string, bool) {
+	return firstTag(ticket.Tags, n.config.Tags.Exclude)
+}
+
+// firstTag returns the first of the wanted tags the ticket has
+func firstTag(tags, wanted []string) (string, bool) {
+	for _, w := range wanted {
+		if hasTag(tags, w) {
+			return w, true
+		}
+	}
+	return "", false
+}
+
+// hasTag reports whether tags contains tag, ignoring case
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
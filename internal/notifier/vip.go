@@ -0,0 +1,112 @@
+package notifier
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/voicetel/freescout-notifier/internal/config"
+	"github.com/voicetel/freescout-notifier/internal/database"
+	"github.com/voicetel/freescout-notifier/internal/models"
+)
+
+// VIPList identifies tickets from VIP customers
+type VIPList struct {
+	emails      map[string]bool
+	domains     map[string]bool
+	customerIDs map[int]bool
+}
+
+type VIPFile struct {
+	Emails      []string `json:"emails"`
+	Domains     []string `json:"domains"`
+	CustomerIDs []int    `json:"customer_ids"`
+}
+
+func NewVIPList(cfg config.VIPConfig) *VIPList {
+	v := &VIPList{
+		emails:      make(map[string]bool),
+		domains:     make(map[string]bool),
+		customerIDs: make(map[int]bool),
+	}
+	v.add(VIPFile{Emails: cfg.Emails, Domains: cfg.Domains, CustomerIDs: cfg.CustomerIDs})
+
+	if cfg.File != "" {
+		if err := v.loadFile(cfg.File); err != nil {
+			log.Printf("Warning: failed to load VIP file %s: %v", cfg.File, err)
+		}
+	}
+
+	return v
+}
+
+// Contains reports whether the ticket belongs to a VIP customer
+func (v *VIPList) Contains(ticket models.Ticket) bool {
+	if v.customerIDs[ticket.CustomerID] {
+		return true
+	}
+
+	email := strings.ToLower(strings.TrimSpace(ticket.CustomerEmail))
+	if email == "" {
+		return false
+	}
+	if v.emails[email] {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	return at >= 0 && v.domains[email[at+1:]]
+}
+
+// Customers returns the list in the form used by the FreeScout queries
+func (v *VIPList) Customers() database.VIPCustomers {
+	var c database.VIPCustomers
+	for email := range v.emails {
+		c.Emails = append(c.Emails, email)
+	}
+	for domain := range v.domains {
+		c.Domains = append(c.Domains, domain)
+	}
+	for id := range v.customerIDs {
+		c.CustomerIDs = append(c.CustomerIDs, id)
+	}
+
+	sort.Strings(c.Emails)
+	sort.Strings(c.Domains)
+	sort.Ints(c.CustomerIDs)
+	return c
+}
+
+func (v *VIPList) add(f VIPFile) {
+	for _, email := range f.Emails {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			v.emails[email] = true
+		}
+	}
+	for _, domain := range f.Domains {
+		if domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@")); domain != "" {
+			v.domains[domain] = true
+		}
+	}
+	for _, id := range f.CustomerIDs {
+		if id > 0 {
+			v.customerIDs[id] = true
+		}
+	}
+}
+
+func (v *VIPList) loadFile(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var f VIPFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return err
+	}
+
+	v.add(f)
+	return nil
+}
@@ -0,0 +1,126 @@
+package pagerduty
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/voicetel/freescout-notifier/internal/channel"
+	"github.com/voicetel/freescout-notifier/internal/config"
+)
+
+// DefaultEventsURL is the PagerDuty Events API v2 enqueue endpoint
+const DefaultEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// Event actions supported by the Events API v2
+const (
+	ActionTrigger = "trigger"
+	ActionResolve = "resolve"
+)
+
+// Client sends PagerDuty Events API v2 trigger and resolve events
+type Client struct {
+	name          string
+	eventsURL     string
+	routingKey    string
+	severity      string
+	httpClient    *http.Client
+	retryAttempts int
+}
+
+// Event is an Events API v2 request body
+type Event struct {
+	RoutingKey  string        `json:"routing_key"`
+	EventAction string        `json:"event_action"`
+	DedupKey    string        `json:"dedup_key"`
+	Payload     *EventPayload `json:"payload,omitempty"`
+	Links       []Link        `json:"links,omitempty"`
+}
+
+type EventPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Component     string            `json:"component,omitempty"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+type Link struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+func NewClient(name string, cfg config.PagerDutyConfig) *Client {
+	return &Client{
+		name:       name,
+		eventsURL:  cfg.EventsURL,
+		routingKey: cfg.RoutingKey,
+		severity:   cfg.Severity,
+		httpClient: &http.Client{
+			Timeout: cfg.Timeout.Duration,
+		},
+		retryAttempts: cfg.RetryAttempts,
+	}
+}
+
+// Name implements channel.Channel
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Send implements channel.Channel by triggering an incident for the ticket
+func (c *Client) Send(n channel.Notification) error {
+	_, _, waitingFor := n.Describe()
+
+	details := map[string]string{
+		"customer":    n.Ticket.CustomerName,
+		"waiting_for": fmt.Sprintf("%s for %s", waitingFor, n.WaitingTime()),
+		"assigned_to": n.AssignedTo(),
+	}
+	if len(n.Ticket.Tags) > 0 {
+		details["tags"] = strings.Join(n.Ticket.Tags, ", ")
+	}
+	if n.TierName != "" {
+		details["escalation"] = n.TierName
+	}
+
+	return c.SendEvent(Event{
+		RoutingKey:  c.routingKey,
+		EventAction: ActionTrigger,
+		DedupKey:    DedupKey(n),
+		Payload: &EventPayload{
+			Summary:       fmt.Sprintf("%s: %s", n.Title(), n.Ticket.Subject),
+			Source:        "freescout-notifier",
+			Severity:      c.severity,
+			Component:     n.Mailbox(),
+			CustomDetails: details,
+		},
+		Links: []Link{{Href: n.TicketURL, Text: "Open in FreeScout"}},
+	})
+}
+
+// Resolve implements channel.Resolver by resolving the ticket's incident
+func (c *Client) Resolve(n channel.Notification) error {
+	return c.SendEvent(Event{
+		RoutingKey:  c.routingKey,
+		EventAction: ActionResolve,
+		DedupKey:    DedupKey(n),
+	})
+}
+
+// DedupKey identifies the incident for a ticket and notification type so
+// repeated triggers update a single incident
+func DedupKey(n channel.Notification) string {
+	return fmt.Sprintf("freescout-%d-%s", n.Ticket.ID, n.Ticket.NotificationType)
+}
+
+// SendEvent posts an event to the Events API
+func (c *Client) SendEvent(e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	return channel.PostJSON(c.httpClient, c.eventsURL, payload, nil, c.retryAttempts)
+}
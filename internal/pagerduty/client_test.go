@@ -0,0 +1,140 @@
+package pagerduty
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/voicetel/freescout-notifier/internal/channel"
+	"github.com/voicetel/freescout-notifier/internal/config"
+	"github.com/voicetel/freescout-notifier/internal/models"
+)
+
+// newFakeEventsAPI starts a stand-in for the Events API that records the
+// decoded body of every event
+func newFakeEventsAPI(t *testing.T) (*httptest.Server, *[]map[string]interface{}) {
+	t.Helper()
+
+	var events []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var event map[string]interface{}
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("invalid event body %s: %v", body, err)
+		}
+		events = append(events, event)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(server.Close)
+	return server, &events
+}
+
+func testNotification() channel.Notification {
+	return channel.Notification{
+		Ticket: models.Ticket{
+			ID:                42,
+			Subject:           "Cannot log in",
+			CustomerName:      "Jane Doe",
+			AssignedUserName:  "John Smith",
+			MailboxID:         1,
+			MailboxName:       "Support",
+			Tags:              []string{"urgent", "bug"},
+			MinutesSinceReply: 300,
+			NotificationType:  models.OpenNoAgentResponse,
+		},
+		Status:    models.StatusSent,
+		TicketURL: "https://support.example.com/conversation/42",
+		TierName:  "Page",
+	}
+}
+
+func TestTriggerAndResolve(t *testing.T) {
+	server, events := newFakeEventsAPI(t)
+	c := NewClient("oncall", config.PagerDutyConfig{EventsURL: server.URL, RoutingKey: "R0UT1NG", Severity: "critical"})
+
+	n := testNotification()
+	if err := c.Send(n); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := c.Resolve(n); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+
+	if len(*events) != 2 {
+		t.Fatalf("received %d events, want 2", len(*events))
+	}
+
+	wantTrigger := map[string]interface{}{
+		"routing_key":  "R0UT1NG",
+		"event_action": "trigger",
+		"dedup_key":    "freescout-42-open_no_agent_response",
+		"payload": map[string]interface{}{
+			"summary":   "Ticket #42 needs attention: Cannot log in",
+			"source":    "freescout-notifier",
+			"severity":  "critical",
+			"component": "Support",
+			"custom_details": map[string]interface{}{
+				"customer":    "Jane Doe",
+				"waiting_for": "agent response for 5 hours",
+				"assigned_to": "John Smith",
+				"tags":        "urgent, bug",
+				"escalation":  "Page",
+			},
+		},
+		"links": []interface{}{
+			map[string]interface{}{"href": "https://support.example.com/conversation/42", "text": "Open in FreeScout"},
+		},
+	}
+	if got := (*events)[0]; !reflect.DeepEqual(got, wantTrigger) {
+		t.Errorf("trigger event = %v, want %v", got, wantTrigger)
+	}
+
+	wantResolve := map[string]interface{}{
+		"routing_key":  "R0UT1NG",
+		"event_action": "resolve",
+		"dedup_key":    "freescout-42-open_no_agent_response",
+	}
+	if got := (*events)[1]; !reflect.DeepEqual(got, wantResolve) {
+		t.Errorf("resolve event = %v, want %v", got, wantResolve)
+	}
+}
+
+func TestDedupKey(t *testing.T) {
+	tests := []struct {
+		ticketID         int
+		notificationType models.NotificationType
+		want             string
+	}{
+		{42, models.OpenNoAgentResponse, "freescout-42-open_no_agent_response"},
+		{42, models.PendingNoCustomerResponse, "freescout-42-pending_no_customer_response"},
+		{7, models.UnassignedConversation, "freescout-7-unassigned_conversation"},
+		{7, models.ReturnedFromPending, "freescout-7-returned_from_pending"},
+	}
+
+	for _, tt := range tests {
+		n := channel.Notification{Ticket: models.Ticket{ID: tt.ticketID, NotificationType: tt.notificationType}}
+		if got := DedupKey(n); got != tt.want {
+			t.Errorf("DedupKey(%d, %s) = %q, want %q", tt.ticketID, tt.notificationType, got, tt.want)
+		}
+	}
+}
+
+func TestSendEventFailure(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	c := NewClient("oncall", config.PagerDutyConfig{EventsURL: server.URL, RoutingKey: "R0UT1NG", RetryAttempts: 1})
+	if err := c.Send(testNotification()); err == nil {
+		t.Error("Send succeeded against an endpoint returning 400")
+	}
+	if calls != 1 {
+		t.Errorf("endpoint called %d times, want 1", calls)
+	}
+}
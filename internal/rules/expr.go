@@ -0,0 +1,460 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// valueType is the static type of an expression
+type valueType int
+
+const (
+	typeBool valueType = iota
+	typeNumber
+	typeString
+	typeNumberList
+	typeStringList
+)
+
+func (t valueType) String() string {
+	switch t {
+	case typeBool:
+		return "bool"
+	case typeNumber:
+		return "number"
+	case typeString:
+		return "string"
+	case typeNumberList:
+		return "list of numbers"
+	default:
+		return "list of strings"
+	}
+}
+
+// elem returns the element type of a list type
+func (t valueType) elem() (valueType, bool) {
+	switch t {
+	case typeNumberList:
+		return typeNumber, true
+	case typeStringList:
+		return typeString, true
+	}
+	return 0, false
+}
+
+// Env holds the values of the variables an expression is evaluated against.
+// Numbers are float64, strings string, booleans bool and lists []float64 or
+// []string.
+type Env map[string]interface{}
+
+// Expr is a compiled, type-checked boolean expression
+type Expr struct {
+	src  string
+	root node
+}
+
+// String returns the source of the expression
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval evaluates the expression against env
+func (e *Expr) Eval(env Env) bool {
+	return e.root.eval(env).(bool)
+}
+
+// Compile parses an expression and checks it against the known variables
+func Compile(src string) (*Expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("at %d: unexpected %s", tok.pos, tok)
+	}
+	if root.typ() != typeBool {
+		return nil, fmt.Errorf("expression is a %s, not a condition", root.typ())
+	}
+
+	return &Expr{src: src, root: root}, nil
+}
+
+// node is a type-checked element of the expression tree
+type node interface {
+	typ() valueType
+	eval(env Env) interface{}
+}
+
+type literal struct {
+	t     valueType
+	value interface{}
+}
+
+func (l literal) typ() valueType       { return l.t }
+func (l literal) eval(Env) interface{} { return l.value }
+
+type variable struct {
+	name string
+	t    valueType
+}
+
+func (v variable) typ() valueType           { return v.t }
+func (v variable) eval(env Env) interface{} { return env[v.name] }
+
+type not struct {
+	operand node
+}
+
+func (n not) typ() valueType { return typeBool }
+func (n not) eval(env Env) interface{} {
+	return !n.operand.eval(env).(bool)
+}
+
+type logical struct {
+	and         bool
+	left, right node
+}
+
+func (l logical) typ() valueType { return typeBool }
+func (l logical) eval(env Env) interface{} {
+	left := l.left.eval(env).(bool)
+	if l.and {
+		return left && l.right.eval(env).(bool)
+	}
+	return left || l.right.eval(env).(bool)
+}
+
+type comparison struct {
+	op          string
+	left, right node
+}
+
+func (c comparison) typ() valueType { return typeBool }
+func (c comparison) eval(env Env) interface{} {
+	left, right := c.left.eval(env), c.right.eval(env)
+
+	switch c.op {
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	}
+
+	l, r := left.(float64), right.(float64)
+	switch c.op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	default:
+		return l >= r
+	}
+}
+
+type match struct {
+	negate bool
+	left   node
+	re     *regexp.Regexp
+}
+
+func (m match) typ() valueType { return typeBool }
+func (m match) eval(env Env) interface{} {
+	return m.re.MatchString(m.left.eval(env).(string)) != m.negate
+}
+
+type membership struct {
+	negate      bool
+	fold        bool // Lower-case the left side, for lists that are lowercased
+	left, right node
+}
+
+func (m membership) typ() valueType { return typeBool }
+func (m membership) eval(env Env) interface{} {
+	left := m.left.eval(env)
+	if m.fold {
+		left = strings.ToLower(left.(string))
+	}
+
+	found := false
+	switch list := m.right.eval(env).(type) {
+	case []string:
+		for _, item := range list {
+			if item == left {
+				found = true
+				break
+			}
+		}
+	case []float64:
+		for _, item := range list {
+			if item == left {
+				found = true
+				break
+			}
+		}
+	}
+	return found != m.negate
+}
+
+// parser is a recursive descent parser for the grammar
+//
+//	or         = and { ("or" | "||") and }
+//	and        = unary { ("and" | "&&") unary }
+//	unary      = ("not" | "!") unary | comparison
+//	comparison = primary [ op primary | ["not"] "in" primary ]
+//	primary    = number | string | "true" | "false" | variable
+//	           | "(" or ")" | "[" [ primary { "," primary } ] "]"
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is one of the given operators or keywords
+func (p *parser) accept(texts ...string) (token, bool) {
+	tok := p.peek()
+	if tok.kind != tokOperator && tok.kind != tokIdent {
+		return tok, false
+	}
+	for _, text := range texts {
+		if tok.text == text {
+			return p.next(), true
+		}
+	}
+	return tok, false
+}
+
+func (p *parser) expect(kind tokenKind, what string) error {
+	if tok := p.next(); tok.kind != kind {
+		return fmt.Errorf("at %d: expected %s, found %s", tok.pos, what, tok)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, ok := p.accept("or", "||")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkBool(tok, left, right); err != nil {
+			return nil, err
+		}
+		left = logical{and: false, left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, ok := p.accept("and", "&&")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkBool(tok, left, right); err != nil {
+			return nil, err
+		}
+		left = logical{and: true, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if tok, ok := p.accept("not", "!"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkBool(tok, operand); err != nil {
+			return nil, err
+		}
+		return not{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	switch {
+	case tok.kind == tokOperator && tok.text != "!" && tok.text != "&&" && tok.text != "||":
+		p.next()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return newComparison(tok, left, right)
+
+	case tok.kind == tokIdent && (tok.text == "in" || tok.text == "not" && p.tokens[p.pos+1].text == "in"):
+		negate := tok.text == "not"
+		p.next()
+		if negate {
+			p.next()
+		}
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		elem, ok := right.typ().elem()
+		if !ok || elem != left.typ() {
+			return nil, fmt.Errorf("at %d: cannot test %s in %s", tok.pos, left.typ(), right.typ())
+		}
+		// Tags are matched case-insensitively, as in tag routing
+		fold := false
+		if v, ok := right.(variable); ok && v.name == "tags" {
+			fold = true
+		}
+		return membership{negate: negate, fold: fold, left: left, right: right}, nil
+	}
+
+	return left, nil
+}
+
+func newComparison(op token, left, right node) (node, error) {
+	switch op.text {
+	case "=~", "!~":
+		pattern, ok := right.(literal)
+		if !ok || left.typ() != typeString || pattern.t != typeString {
+			return nil, fmt.Errorf("at %d: %s needs a string and a quoted regular expression", op.pos, op.text)
+		}
+		re, err := regexp.Compile(pattern.value.(string))
+		if err != nil {
+			return nil, fmt.Errorf("at %d: %w", op.pos, err)
+		}
+		return match{negate: op.text == "!~", left: left, re: re}, nil
+
+	case "==", "!=":
+		if left.typ() != right.typ() || left.typ() == typeNumberList || left.typ() == typeStringList {
+			return nil, fmt.Errorf("at %d: cannot compare %s %s %s", op.pos, left.typ(), op.text, right.typ())
+		}
+
+	default:
+		if left.typ() != typeNumber || right.typ() != typeNumber {
+			return nil, fmt.Errorf("at %d: %s needs numbers, found %s and %s", op.pos, op.text, left.typ(), right.typ())
+		}
+	}
+
+	return comparison{op: op.text, left: left, right: right}, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokNumber:
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("at %d: invalid number %q", tok.pos, tok.text)
+		}
+		return literal{t: typeNumber, value: value}, nil
+
+	case tokString:
+		return literal{t: typeString, value: tok.text}, nil
+
+	case tokIdent:
+		switch tok.text {
+		case "true", "false":
+			return literal{t: typeBool, value: tok.text == "true"}, nil
+		}
+		t, ok := Variables[tok.text]
+		if !ok {
+			return nil, fmt.Errorf("at %d: unknown variable %q", tok.pos, tok.text)
+		}
+		return variable{name: tok.text, t: t}, nil
+
+	case tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRParen, `")"`); err != nil {
+			return nil, err
+		}
+		return inner, nil
+
+	case tokLBracket:
+		return p.parseList(tok)
+	}
+
+	return nil, fmt.Errorf("at %d: unexpected %s", tok.pos, tok)
+}
+
+// parseList reads a list literal of numbers or strings
+func (p *parser) parseList(open token) (node, error) {
+	var numbers []float64
+	var strs []string
+
+	for p.peek().kind != tokRBracket {
+		if len(numbers)+len(strs) > 0 {
+			if err := p.expect(tokComma, `","`); err != nil {
+				return nil, err
+			}
+		}
+		item, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		value, ok := item.(literal)
+		switch {
+		case ok && value.t == typeNumber && len(strs) == 0:
+			numbers = append(numbers, value.value.(float64))
+		case ok && value.t == typeString && len(numbers) == 0:
+			strs = append(strs, value.value.(string))
+		default:
+			return nil, fmt.Errorf("at %d: lists may only hold numbers or only strings", open.pos)
+		}
+	}
+	p.next()
+
+	if len(numbers) > 0 {
+		return literal{t: typeNumberList, value: numbers}, nil
+	}
+	return literal{t: typeStringList, value: strs}, nil
+}
+
+func checkBool(op token, operands ...node) error {
+	for _, operand := range operands {
+		if operand.typ() != typeBool {
+			return fmt.Errorf("at %d: %s needs conditions, found %s", op.pos, op.text, operand.typ())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/voicetel/freescout-notifier/internal/models"
+)
+
+func testEnv() Env {
+	assignee := 7
+	return NewEnv(models.Ticket{
+		ID:                42,
+		Subject:           "Invoice 1234 overdue",
+		CustomerEmail:     "Jane@Example.com",
+		AssignedUserID:    &assignee,
+		AssignedUserName:  "John Smith ",
+		MailboxID:         2,
+		MailboxName:       "Billing",
+		Tags:              []string{"urgent", "Invoice"},
+		MinutesSinceReply: 90,
+		NotificationType:  models.OpenNoAgentResponse,
+	})
+}
+
+func TestCompileEval(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		// Literals and variables
+		{`true`, true},
+		{`vip`, false},
+		{`assigned`, true},
+
+		// Comparisons
+		{`mailbox == 2`, true},
+		{`mailbox != 2`, false},
+		{`minutes_waiting > 60`, true},
+		{`minutes_waiting >= 90`, true},
+		{`minutes_waiting < 90`, false},
+		{`minutes_waiting <= 89.5`, false},
+		{`mailbox_name == "Billing"`, true},
+		{`assignee == 'John Smith'`, true},
+		{`customer_domain == "example.com"`, true},
+		{`status == "open" && type == "open_no_agent_response"`, true},
+
+		// Precedence: and binds tighter than or, not tighter than and
+		{`true or false and false`, true},
+		{`(true or false) and false`, false},
+		{`false and false or true`, true},
+		{`not false and false`, false},
+		{`not (false and false)`, true},
+		{`!vip && mailbox == 2 || minutes_waiting < 10`, true},
+		{`vip || mailbox == 3 && assigned`, false},
+		{`not not assigned`, true},
+
+		// Membership
+		{`"urgent" in tags`, true},
+		{`"vip" in tags`, false},
+		{`"vip" not in tags`, true},
+		{`"urgent" not in tags`, false},
+		{`mailbox in [1, 2, 3]`, true},
+		{`mailbox not in [1, 3]`, true},
+		{`customer_domain in ["example.com", "example.org"]`, true},
+		{`"urgent" in []`, false},
+		{`"URGENT" in tags`, true},
+		{`"Invoice" not in tags`, false},
+		{`"urgent" in ["URGENT"]`, false},
+
+		// Regular expressions
+		{`subject =~ "(?i)invoice \d+"`, true},
+		{`subject !~ "refund"`, true},
+		{`subject =~ "^refund"`, false},
+		{`customer_email =~ "@example\.com$"`, true},
+		{`subject =~ "say \"hi\""`, false},
+	}
+
+	env := testEnv()
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile(%q): %v", tt.expr, err)
+			}
+			if got := expr.Eval(env); got != tt.want {
+				t.Errorf("Eval(%q) = %t, want %t", tt.expr, got, tt.want)
+			}
+			if expr.String() != tt.expr {
+				t.Errorf("String() = %q, want %q", expr.String(), tt.expr)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		// Malformed input
+		{``, "unexpected end of expression"},
+		{`not`, "unexpected end of expression"},
+		{`!`, "unexpected end of expression"},
+		{`[1,`, "unexpected end of expression"},
+		{`[1`, `expected ","`},
+		{`vip ==`, "unexpected end of expression"},
+		{`"unterminated`, "unterminated string"},
+		{`'unterminated\`, "unterminated string"},
+		{`(vip`, `expected ")"`},
+		{`vip)`, `unexpected ")"`},
+		{`vip and`, "unexpected end of expression"},
+		{`mailbox not`, `unexpected "not"`},
+		{`mailbox == 2 ==`, `unexpected "=="`},
+		{`mailbox = 2`, "unexpected character"},
+		{`mailbox == 2 $`, "unexpected character"},
+		{`1.2.3 > mailbox`, "invalid number"},
+
+		// Unknown variables
+		{`a == 1`, `unknown variable "a"`},
+
+		// Type errors
+		{`mailbox`, "is a number, not a condition"},
+		{`"urgent"`, "is a string, not a condition"},
+		{`mailbox == "2"`, "cannot compare number == string"},
+		{`tags == tags`, "cannot compare list of strings"},
+		{`subject > 5`, "> needs numbers"},
+		{`vip and mailbox`, "and needs conditions"},
+		{`not mailbox`, "not needs conditions"},
+		{`mailbox in tags`, "cannot test number in list of strings"},
+		{`"urgent" in subject`, "cannot test string in string"},
+		{`[1, "a"]`, "lists may only hold numbers or only strings"},
+		{`mailbox in [1, vip]`, "lists may only hold numbers or only strings"},
+
+		// Regular expressions
+		{`subject =~ "("`, "missing closing )"},
+		{`subject =~ subject`, "needs a string and a quoted regular expression"},
+		{`mailbox =~ "1"`, "needs a string and a quoted regular expression"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := Compile(tt.expr)
+			if err == nil {
+				t.Fatalf("Compile(%q) = %v, want error containing %q", tt.expr, expr, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Compile(%q) error = %q, want it to contain %q", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
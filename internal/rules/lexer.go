@@ -0,0 +1,125 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOperator
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// operators lists the symbolic operators, longest first so that "==" is
+// matched before "="
+var operators = []string{"==", "!=", "<=", ">=", "=~", "!~", "&&", "||", "<", ">", "!"}
+
+// lex splits an expression into tokens
+func lex(src string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '(' || c == ')' || c == '[' || c == ']' || c == ',':
+			kinds := map[rune]tokenKind{'(': tokLParen, ')': tokRParen, '[': tokLBracket, ']': tokRBracket, ',': tokComma}
+			tokens = append(tokens, token{kind: kinds[c], text: string(c), pos: i})
+			i++
+
+		case c == '"' || c == '\'':
+			text, n, err := lexString(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("at %d: %w", i, err)
+			}
+			tokens = append(tokens, token{kind: tokString, text: text, pos: i})
+			i += n
+
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[start:i], pos: start})
+
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i < len(src) && (src[i] == '_' || unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i]))) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: src[start:i], pos: start})
+
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, token{kind: tokOperator, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("at %d: unexpected character %q", i, c)
+			}
+		}
+	}
+
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// lexString reads a quoted string literal, returning its unescaped value and
+// the number of bytes consumed
+func lexString(src string) (string, int, error) {
+	quote := src[0]
+	var b strings.Builder
+
+	for i := 1; i < len(src); i++ {
+		switch src[i] {
+		case quote:
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 == len(src) {
+				return "", 0, fmt.Errorf("unterminated string")
+			}
+			// Only quotes and backslashes are escaped so that regular
+			// expressions such as "\d+" can be written as-is
+			i++
+			if src[i] != quote && src[i] != '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(src[i])
+		default:
+			b.WriteByte(src[i])
+		}
+	}
+
+	return "", 0, fmt.Errorf("unterminated string")
+}
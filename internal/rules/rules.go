@@ -0,0 +1,133 @@
+// Package rules evaluates the configurable rules that decide how each
+// ticket is alerted.
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/voicetel/freescout-notifier/internal/config"
+	"github.com/voicetel/freescout-notifier/internal/models"
+)
+
+// Action is what happens to a ticket matched by a rule
+type Action string
+
+const (
+	ActionNotify   Action = "notify"   // Send to the rule's channels instead of the routed ones
+	ActionSuppress Action = "suppress" // Do not alert
+	ActionEscalate Action = "escalate" // Raise the ticket to at least the rule's escalation tier
+)
+
+// Variables lists the ticket attributes available to expressions
+var Variables = map[string]valueType{
+	"mailbox":         typeNumber,
+	"mailbox_name":    typeString,
+	"tags":            typeStringList,
+	"assigned":        typeBool,
+	"assignee":        typeString,
+	"assignee_id":     typeNumber,
+	"customer_email":  typeString,
+	"customer_domain": typeString,
+	"vip":             typeBool,
+	"minutes_waiting": typeNumber,
+	"status":          typeString,
+	"type":            typeString,
+	"subject":         typeString,
+}
+
+// Rule is a compiled rule
+type Rule struct {
+	Name     string
+	Action   Action
+	Channels []string
+	Tier     int
+	When     *Expr
+}
+
+// Engine evaluates rules in order against tickets
+type Engine struct {
+	rules []Rule
+}
+
+// New compiles the configured rules
+func New(cfgs []config.RuleConfig) (*Engine, error) {
+	engine := &Engine{}
+
+	for i, cfg := range cfgs {
+		name := cfg.Name
+		if name == "" {
+			name = fmt.Sprintf("rules[%d]", i)
+		}
+
+		expr, err := Compile(cfg.When)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", name, err)
+		}
+
+		engine.rules = append(engine.rules, Rule{
+			Name:     name,
+			Action:   Action(cfg.Action),
+			Channels: cfg.Channels,
+			Tier:     cfg.Tier,
+			When:     expr,
+		})
+	}
+
+	return engine, nil
+}
+
+// Match returns the first rule whose condition holds for the ticket, or nil
+func (e *Engine) Match(ticket models.Ticket) *Rule {
+	if e == nil || len(e.rules) == 0 {
+		return nil
+	}
+
+	env := NewEnv(ticket)
+	for i := range e.rules {
+		if e.rules[i].When.Eval(env) {
+			return &e.rules[i]
+		}
+	}
+	return nil
+}
+
+// NewEnv returns the expression variables describing a ticket
+func NewEnv(ticket models.Ticket) Env {
+	email := strings.ToLower(strings.TrimSpace(ticket.CustomerEmail))
+	domain := ""
+	if at := strings.LastIndex(email, "@"); at >= 0 {
+		domain = email[at+1:]
+	}
+
+	assigneeID := 0
+	if ticket.AssignedUserID != nil {
+		assigneeID = *ticket.AssignedUserID
+	}
+
+	status := "open"
+	if ticket.NotificationType == models.PendingNoCustomerResponse {
+		status = "pending"
+	}
+
+	tags := make([]string, len(ticket.Tags))
+	for i, tag := range ticket.Tags {
+		tags[i] = strings.ToLower(tag)
+	}
+
+	return Env{
+		"mailbox":         float64(ticket.MailboxID),
+		"mailbox_name":    ticket.MailboxName,
+		"tags":            tags,
+		"assigned":        ticket.AssignedUserID != nil,
+		"assignee":        strings.TrimSpace(ticket.AssignedUserName),
+		"assignee_id":     float64(assigneeID),
+		"customer_email":  email,
+		"customer_domain": domain,
+		"vip":             ticket.VIP,
+		"minutes_waiting": float64(ticket.MinutesSinceReply),
+		"status":          status,
+		"type":            string(ticket.NotificationType),
+		"subject":         ticket.Subject,
+	}
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/voicetel/freescout-notifier/internal/database"
+	"github.com/voicetel/freescout-notifier/internal/models"
+	"github.com/voicetel/freescout-notifier/internal/slack"
+)
+
+// maxRequestAge is how far a request timestamp may drift from the current
+// time before it is rejected as a possible replay
+const maxRequestAge = 5 * time.Minute
+
+// errInvalidValue is returned for a button value that cannot be parsed,
+// which only a malformed or forged request can send
+var errInvalidValue = errors.New("malformed button value")
+
+// Server receives Slack interaction payloads from the alert buttons and
+// records the resulting actions in the local database
+type Server struct {
+	db            *database.DB
+	signingSecret []byte
+	httpClient    *http.Client
+	now           func() time.Time
+}
+
+// InteractionPayload holds the fields of a block_actions payload used by
+// the server
+type InteractionPayload struct {
+	Type        string `json:"type"`
+	ResponseURL string `json:"response_url"`
+	User        struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+		Name     string `json:"name"`
+	} `json:"user"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+func New(db *database.DB, signingSecret string) *Server {
+	return &Server{
+		db:            db,
+		signingSecret: []byte(signingSecret),
+		httpClient:    &http.Client{Timeout: 10 * time.Second},
+		now:           time.Now,
+	}
+}
+
+// Handler returns the HTTP handler serving the interaction endpoint
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slack/interactions", s.handleInteraction)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return mux
+}
+
+func (s *Server) handleInteraction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.verify(r.Header, body); err != nil {
+		log.Printf("Rejected Slack interaction: %v", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	// Restore the body so the form can be parsed after verification
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+
+	var payload InteractionPayload
+	if err := json.Unmarshal([]byte(r.PostForm.Get("payload")), &payload); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	var replies []string
+	if payload.Type == "block_actions" {
+		for _, action := range payload.Actions {
+			reply, err := s.handleAction(payload, action.ActionID, action.Value)
+			if errors.Is(err, errInvalidValue) {
+				log.Printf("Rejected Slack action %s: %v", action.ActionID, err)
+				http.Error(w, "invalid action value", http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				log.Printf("Error handling Slack action %s: %v", action.ActionID, err)
+				http.Error(w, "failed to record action", http.StatusInternalServerError)
+				return
+			}
+			if reply != "" && payload.ResponseURL != "" {
+				replies = append(replies, reply)
+			}
+		}
+	}
+
+	// Slack expects the acknowledgement within three seconds, so the
+	// confirmations are posted after responding
+	w.WriteHeader(http.StatusOK)
+	for _, reply := range replies {
+		go s.respond(payload.ResponseURL, reply)
+	}
+}
+
+// handleAction records a button action and returns the confirmation shown
+// to the user, or an empty string for actions that need no handling
+func (s *Server) handleAction(payload InteractionPayload, actionID, value string) (string, error) {
+	var actionType models.TicketActionType
+	var snooze time.Duration
+
+	switch actionID {
+	case slack.ActionAcknowledge:
+		actionType = models.ActionAcknowledge
+	case slack.ActionSnooze1h:
+		actionType, snooze = models.ActionSnooze, time.Hour
+	case slack.ActionSnooze4h:
+		actionType, snooze = models.ActionSnooze, 4*time.Hour
+	case slack.ActionAssignToMe:
+		actionType = models.ActionAssign
+	default:
+		// Link buttons such as "Open in FreeScout" also send a payload
+		return "", nil
+	}
+
+	ticketID, notificationType, lastReplyAt, err := slack.ParseActionValue(value)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errInvalidValue, err)
+	}
+
+	action := models.TicketAction{
+		TicketID:         ticketID,
+		NotificationType: notificationType,
+		Action:           actionType,
+		SlackUserID:      payload.User.ID,
+		SlackUserName:    payload.User.Username,
+		LastReplyAt:      lastReplyAt,
+	}
+	if snooze > 0 {
+		until := s.now().Add(snooze)
+		action.Until = &until
+	}
+
+	if err := s.db.RecordTicketAction(action); err != nil {
+		return "", err
+	}
+
+	log.Printf("Recorded %s on ticket %d by %s", actionType, ticketID, payload.User.ID)
+
+	switch actionType {
+	case models.ActionAcknowledge:
+		return fmt.Sprintf("<@%s> acknowledged ticket #%d. Reminders are paused until it gets a new reply.", payload.User.ID, ticketID), nil
+	case models.ActionSnooze:
+		return fmt.Sprintf("<@%s> snoozed ticket #%d for %s.", payload.User.ID, ticketID, snooze), nil
+	default:
+		return fmt.Sprintf("<@%s> is now the owner of ticket #%d. Reminders are paused until it gets a new reply.", payload.User.ID, ticketID), nil
+	}
+}
+
+// verify checks the Slack request signature and timestamp
+func (s *Server) verify(header http.Header, body []byte) error {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	signature := header.Get("X-Slack-Signature")
+	if timestamp == "" || signature == "" {
+		return fmt.Errorf("missing signature headers")
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	age := s.now().Sub(time.Unix(ts, 0))
+	if age > maxRequestAge || age < -maxRequestAge {
+		return fmt.Errorf("request timestamp too old")
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(Sign(s.signingSecret, timestamp, body))) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// Sign computes the X-Slack-Signature value for a request
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "v0:%s:", timestamp)
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// respond posts an ephemeral confirmation to the interaction's response URL
+func (s *Server) respond(responseURL, text string) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"response_type":    "ephemeral",
+		"replace_original": false,
+		"text":             text,
+	})
+	if err != nil {
+		return
+	}
+
+	resp, err := s.httpClient.Post(responseURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		log.Printf("Error responding to Slack interaction: %v", err)
+		return
+	}
+	resp.Body.Close()
+}
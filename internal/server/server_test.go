@@ -0,0 +1,324 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/voicetel/freescout-notifier/internal/database"
+	"github.com/voicetel/freescout-notifier/internal/models"
+	"github.com/voicetel/freescout-notifier/internal/slack"
+)
+
+const testSecret = "test-signing-secret"
+
+var testNow = time.Date(2025, 1, 15, 16, 30, 0, 0, time.UTC)
+
+func newTestServer(t *testing.T) (*Server, *database.DB) {
+	t.Helper()
+
+	db, err := database.InitSQLite(filepath.Join(t.TempDir(), "notifications.db"))
+	if err != nil {
+		t.Fatalf("InitSQLite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.InitSchema(db); err != nil {
+		t.Fatalf("InitSchema: %v", err)
+	}
+
+	s := New(db, testSecret)
+	s.now = func() time.Time { return testNow }
+	return s, db
+}
+
+// interactionBody encodes a block_actions payload as Slack sends it
+func interactionBody(t *testing.T, actionID, value, responseURL string) []byte {
+	t.Helper()
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"type":         "block_actions",
+		"response_url": responseURL,
+		"user":         map[string]string{"id": "U123", "username": "jane"},
+		"actions":      []map[string]string{{"action_id": actionID, "value": value}},
+	})
+	if err != nil {
+		t.Fatalf("marshal payload: %v", err)
+	}
+	return []byte(url.Values{"payload": {string(payload)}}.Encode())
+}
+
+// signedRequest builds a request signed with secret at the given time
+func signedRequest(body []byte, secret string, at time.Time) *http.Request {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	r := httptest.NewRequest(http.MethodPost, "/slack/interactions", strings.NewReader(string(body)))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	r.Header.Set("X-Slack-Signature", Sign([]byte(secret), timestamp, body))
+	return r
+}
+
+func TestHandleInteractionVerifiesSignature(t *testing.T) {
+	value := fmt.Sprintf("42:open_no_agent_response:%d", testNow.Add(-3*time.Hour).Unix())
+
+	tests := []struct {
+		name   string
+		secret string
+		at     time.Time
+		want   int
+	}{
+		{"valid signature", testSecret, testNow, http.StatusOK},
+		{"bad signature", "wrong-secret", testNow, http.StatusUnauthorized},
+		{"stale timestamp", testSecret, testNow.Add(-6 * time.Minute), http.StatusUnauthorized},
+		{"future timestamp", testSecret, testNow.Add(6 * time.Minute), http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, db := newTestServer(t)
+			body := interactionBody(t, slack.ActionAcknowledge, value, "")
+
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, signedRequest(body, tt.secret, tt.at))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			wantRows := 0
+			if tt.want == http.StatusOK {
+				wantRows = 1
+			}
+			if rows := countActions(t, db); rows != wantRows {
+				t.Errorf("ticket_actions rows = %d, want %d", rows, wantRows)
+			}
+		})
+	}
+}
+
+func TestHandleInteractionMissingHeaders(t *testing.T) {
+	s, _ := newTestServer(t)
+	body := interactionBody(t, slack.ActionAcknowledge, "42:open_no_agent_response:0", "")
+
+	r := httptest.NewRequest(http.MethodPost, "/slack/interactions", strings.NewReader(string(body)))
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestHandleInteractionActions(t *testing.T) {
+	lastReply := testNow.Add(-3 * time.Hour).Truncate(time.Second)
+	value := fmt.Sprintf("42:open_no_agent_response:%d", lastReply.Unix())
+
+	tests := []struct {
+		actionID   string
+		wantAction string
+		wantUntil  time.Duration // 0 when the action has no expiry
+		wantReply  string
+	}{
+		{slack.ActionAcknowledge, "acknowledge", 0, "acknowledged ticket #42"},
+		{slack.ActionSnooze1h, "snooze", time.Hour, "snoozed ticket #42 for 1h0m0s"},
+		{slack.ActionSnooze4h, "snooze", 4 * time.Hour, "snoozed ticket #42 for 4h0m0s"},
+		{slack.ActionAssignToMe, "assign", 0, "is now the owner of ticket #42"},
+		{slack.ActionOpenTicket, "", 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.actionID, func(t *testing.T) {
+			s, db := newTestServer(t)
+
+			replies := make(chan string, 1)
+			responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				var reply struct {
+					Text string `json:"text"`
+				}
+				json.Unmarshal(body, &reply)
+				replies <- reply.Text
+			}))
+			defer responder.Close()
+
+			body := interactionBody(t, tt.actionID, value, responder.URL)
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, signedRequest(body, testSecret, testNow))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+
+			if tt.wantAction == "" {
+				if rows := countActions(t, db); rows != 0 {
+					t.Errorf("ticket_actions rows = %d, want 0", rows)
+				}
+				return
+			}
+
+			var (
+				ticketID         int
+				notificationType string
+				action           string
+				slackUserID      string
+				slackUserName    string
+				lastReplyUnix    int64
+				untilUnix        sql.NullInt64
+			)
+			err := db.QueryRow(`
+				SELECT ticket_id, notification_type, action, slack_user_id, slack_user_name, last_reply_unix, until_unix
+				FROM ticket_actions
+			`).Scan(&ticketID, &notificationType, &action, &slackUserID, &slackUserName, &lastReplyUnix, &untilUnix)
+			if err != nil {
+				t.Fatalf("query ticket_actions: %v", err)
+			}
+
+			if ticketID != 42 || notificationType != "open_no_agent_response" || action != tt.wantAction {
+				t.Errorf("row = (%d, %s, %s), want (42, open_no_agent_response, %s)", ticketID, notificationType, action, tt.wantAction)
+			}
+			if slackUserID != "U123" || slackUserName != "jane" {
+				t.Errorf("user = (%s, %s), want (U123, jane)", slackUserID, slackUserName)
+			}
+			if lastReplyUnix != lastReply.Unix() {
+				t.Errorf("last_reply_unix = %d, want %d", lastReplyUnix, lastReply.Unix())
+			}
+			switch {
+			case tt.wantUntil == 0 && untilUnix.Valid:
+				t.Errorf("until_unix = %d, want NULL", untilUnix.Int64)
+			case tt.wantUntil > 0 && untilUnix.Int64 != testNow.Add(tt.wantUntil).Unix():
+				t.Errorf("until_unix = %d, want %d", untilUnix.Int64, testNow.Add(tt.wantUntil).Unix())
+			}
+
+			select {
+			case reply := <-replies:
+				if !strings.Contains(reply, tt.wantReply) {
+					t.Errorf("reply = %q, want it to contain %q", reply, tt.wantReply)
+				}
+			case <-time.After(5 * time.Second):
+				t.Error("no reply posted to the response URL")
+			}
+		})
+	}
+}
+
+func TestAssignToMeSetsOwner(t *testing.T) {
+	s, db := newTestServer(t)
+	lastReply := testNow.Add(-3 * time.Hour)
+	value := fmt.Sprintf("42:open_no_agent_response:%d", lastReply.Unix())
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, signedRequest(interactionBody(t, slack.ActionAssignToMe, value, ""), testSecret, testNow))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	tickets := []models.Ticket{{ID: 42}, {ID: 43}}
+	if err := db.LoadTicketOwners(tickets); err != nil {
+		t.Fatalf("LoadTicketOwners: %v", err)
+	}
+	if tickets[0].OwnerSlackID != "U123" || tickets[0].OwnerName != "jane" {
+		t.Errorf("owner of #42 = (%q, %q), want (U123, jane)", tickets[0].OwnerSlackID, tickets[0].OwnerName)
+	}
+	if tickets[1].OwnerSlackID != "" || tickets[1].OwnerName != "" {
+		t.Errorf("owner of #43 = (%q, %q), want none", tickets[1].OwnerSlackID, tickets[1].OwnerName)
+	}
+
+	suppressed, err := db.IsTicketSuppressed(42, lastReply, testNow)
+	if err != nil {
+		t.Fatalf("IsTicketSuppressed: %v", err)
+	}
+	if !suppressed {
+		t.Error("reminders not suppressed after Assign to me")
+	}
+}
+
+func TestHandleInteractionRespondsBeforeReply(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	release := make(chan struct{})
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer responder.Close()
+	defer close(release)
+
+	value := fmt.Sprintf("42:open_no_agent_response:%d", testNow.Unix())
+	body := interactionBody(t, slack.ActionAcknowledge, value, responder.URL)
+
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, signedRequest(body, testSecret, testNow))
+		done <- w.Code
+	}()
+
+	select {
+	case code := <-done:
+		if code != http.StatusOK {
+			t.Errorf("status = %d, want %d", code, http.StatusOK)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler waited for the response URL before acknowledging")
+	}
+}
+
+func TestHandleInteractionInvalidValue(t *testing.T) {
+	values := []string{
+		"not-a-value",
+		"42:open_no_agent_response",
+		"abc:open_no_agent_response:0",
+		"42:open_no_agent_response:yesterday",
+		"42:open_no_agent_response:0:extra",
+	}
+
+	for _, value := range values {
+		t.Run(value, func(t *testing.T) {
+			s, db := newTestServer(t)
+			body := interactionBody(t, slack.ActionAcknowledge, value, "")
+
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, signedRequest(body, testSecret, testNow))
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			if rows := countActions(t, db); rows != 0 {
+				t.Errorf("ticket_actions rows = %d, want 0", rows)
+			}
+		})
+	}
+}
+
+func TestHandleInteractionRecordFailure(t *testing.T) {
+	s, db := newTestServer(t)
+	if _, err := db.Exec("DROP TABLE ticket_actions"); err != nil {
+		t.Fatalf("drop ticket_actions: %v", err)
+	}
+
+	value := fmt.Sprintf("42:open_no_agent_response:%d", testNow.Unix())
+	body := interactionBody(t, slack.ActionAcknowledge, value, "")
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, signedRequest(body, testSecret, testNow))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+}
+
+func countActions(t *testing.T, db *database.DB) int {
+	t.Helper()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM ticket_actions").Scan(&count); err != nil {
+		t.Fatalf("count ticket_actions: %v", err)
+	}
+	return count
+}
@@ -0,0 +1,80 @@
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/voicetel/freescout-notifier/internal/channel"
+)
+
+// APIResponse holds the fields of a Web API response used by the client
+type APIResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// PostMessage sends a message through chat.postMessage and returns the
+// channel and timestamp Slack assigned to it
+func (c *Client) PostMessage(message Message) (*APIResponse, error) {
+	return c.call("chat.postMessage", message)
+}
+
+// UpdateMessage replaces the text of a previously posted message through
+// chat.update
+func (c *Client) UpdateMessage(message Message) (*APIResponse, error) {
+	return c.call("chat.update", message)
+}
+
+// call invokes a Web API method with a JSON body, retrying transport
+// failures, rate limiting and server errors
+func (c *Client) call(method string, body interface{}) (*APIResponse, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var result APIResponse
+	err = channel.Retry(c.retryAttempts, func() error {
+		req, err := http.NewRequest("POST", c.apiURL+"/"+method, bytes.NewReader(payload))
+		if err != nil {
+			return channel.Permanent(fmt.Errorf("failed to create request: %w", err))
+		}
+
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		req.Header.Set("Authorization", "Bearer "+c.botToken)
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			err := fmt.Errorf("slack %s returned status %d", method, resp.StatusCode)
+			// Other client errors, such as a bad token or an unknown method,
+			// fail the same way on every attempt
+			if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+				return channel.Permanent(err)
+			}
+			return err
+		}
+
+		result = APIResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return fmt.Errorf("failed to decode %s response: %w", method, err)
+		}
+		if !result.OK {
+			return channel.Permanent(fmt.Errorf("slack %s failed: %s", method, result.Error))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
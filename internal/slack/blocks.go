@@ -0,0 +1,215 @@
+package slack
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/voicetel/freescout-notifier/internal/channel"
+	"github.com/voicetel/freescout-notifier/internal/models"
+)
+
+// Block is a Block Kit layout block. Only the fields used by the header,
+// section, context, divider and actions block types are modelled.
+type Block struct {
+	Type     string        `json:"type"`
+	BlockID  string        `json:"block_id,omitempty"`
+	Text     *TextObject   `json:"text,omitempty"`
+	Fields   []TextObject  `json:"fields,omitempty"`
+	Elements []interface{} `json:"elements,omitempty"`
+}
+
+// TextObject is a plain_text or mrkdwn composition object
+type TextObject struct {
+	Type  string `json:"type"`
+	Text  string `json:"text"`
+	Emoji bool   `json:"emoji,omitempty"`
+}
+
+// Button is an interactive button element
+type Button struct {
+	Type     string     `json:"type"`
+	Text     TextObject `json:"text"`
+	URL      string     `json:"url,omitempty"`
+	ActionID string     `json:"action_id,omitempty"`
+	Value    string     `json:"value,omitempty"`
+	Style    string     `json:"style,omitempty"`
+}
+
+// Attachment is a secondary message attachment, used for a colored sidebar
+type Attachment struct {
+	Color    string  `json:"color,omitempty"`
+	Fallback string  `json:"fallback,omitempty"`
+	Blocks   []Block `json:"blocks,omitempty"`
+}
+
+func plainText(text string) *TextObject {
+	return &TextObject{Type: "plain_text", Text: text, Emoji: true}
+}
+
+func mrkdwn(text string) TextObject {
+	return TextObject{Type: "mrkdwn", Text: text}
+}
+
+// escape escapes the characters Slack treats as control sequences in mrkdwn
+func escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// batchSize is the number of tickets per message of a queued burst, keeping
+// each message under Slack's limit of 50 blocks
+const batchSize = 20
+
+// Action IDs of the interactive alert buttons
+const (
+	ActionOpenTicket  = "open_ticket"
+	ActionAcknowledge = "acknowledge"
+	ActionSnooze1h    = "snooze_1h"
+	ActionSnooze4h    = "snooze_4h"
+	ActionAssignToMe  = "assign_to_me"
+)
+
+// NewAlertMessage renders a notification with Block Kit, keeping the mrkdwn
+// text as the fallback shown in notifications and older clients. Interactive
+// alerts carry acknowledge, snooze and assign buttons.
+func NewAlertMessage(n channel.Notification, interactive bool) Message {
+	return Message{
+		Text:   FormatMessage(n),
+		Blocks: FormatBlocks(n, interactive),
+	}
+}
+
+// ActionValue encodes the ticket an interactive button refers to, including
+// its last reply time so acknowledgements expire when the ticket changes
+func ActionValue(n channel.Notification) string {
+	return fmt.Sprintf("%d:%s:%d", n.Ticket.ID, n.Ticket.NotificationType, n.Ticket.LastReplyAt.Unix())
+}
+
+// ParseActionValue decodes a value produced by ActionValue
+func ParseActionValue(value string) (int, models.NotificationType, time.Time, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0, "", time.Time{}, fmt.Errorf("invalid action value %q", value)
+	}
+
+	ticketID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, "", time.Time{}, fmt.Errorf("invalid ticket ID in action value %q", value)
+	}
+	lastReply, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return 0, "", time.Time{}, fmt.Errorf("invalid last reply in action value %q", value)
+	}
+
+	return ticketID, models.NotificationType(parts[1]), time.Unix(lastReply, 0), nil
+}
+
+// FormatBlocks renders a notification as a header, a field section, a
+// context line and a link button, followed by the action buttons when
+// interactive is set
+func FormatBlocks(n channel.Notification, interactive bool) []Block {
+	_, _, waitingFor := n.Describe()
+
+	blocks := []Block{
+		{
+			Type: "header",
+			Text: plainText(n.Headline()),
+		},
+		{
+			Type: "section",
+			Text: &TextObject{Type: "mrkdwn", Text: fmt.Sprintf("*%s*", escape(n.Ticket.Subject))},
+			Fields: []TextObject{
+				mrkdwn(fmt.Sprintf("*Customer:*\n%s", escape(n.Ticket.CustomerName))),
+				mrkdwn(fmt.Sprintf("*Assigned to:*\n%s", escape(n.AssignedTo()))),
+				mrkdwn(fmt.Sprintf("*Waiting for:*\n%s for %s", waitingFor, n.WaitingTime())),
+				mrkdwn(fmt.Sprintf("*Mailbox:*\n%s", escape(n.Mailbox()))),
+			},
+		},
+	}
+	if len(n.Ticket.Tags) > 0 {
+		tags := make([]string, len(n.Ticket.Tags))
+		for i, tag := range n.Ticket.Tags {
+			tags[i] = "`" + escape(tag) + "`"
+		}
+		blocks[1].Fields = append(blocks[1].Fields, mrkdwn(fmt.Sprintf("*Tags:*\n%s", strings.Join(tags, " "))))
+	}
+
+	context := []interface{}{mrkdwn(fmt.Sprintf("Conversation #%d", n.Ticket.Number))}
+	if !n.Ticket.LastReplyAt.IsZero() {
+		context = append(context, mrkdwn(fmt.Sprintf("Last reply <!date^%d^{date_short_pretty} at {time}|%s>",
+			n.Ticket.LastReplyAt.Unix(), n.Ticket.LastReplyAt.UTC().Format("2006-01-02 15:04 UTC"))))
+	}
+	if n.TierName != "" {
+		context = append(context, mrkdwn(fmt.Sprintf("Escalation: %s", escape(n.TierName))))
+	}
+	if n.Status == models.StatusQueued {
+		context = append(context, mrkdwn("Held outside business hours"))
+	}
+	blocks = append(blocks, Block{Type: "context", Elements: context})
+
+	buttons := []interface{}{
+		Button{
+			Type:     "button",
+			Text:     *plainText("Open in FreeScout"),
+			URL:      n.TicketURL,
+			ActionID: ActionOpenTicket,
+		},
+	}
+	if interactive {
+		value := ActionValue(n)
+		buttons = append(buttons,
+			Button{Type: "button", Text: *plainText("Acknowledge"), ActionID: ActionAcknowledge, Value: value, Style: "primary"},
+			Button{Type: "button", Text: *plainText("Snooze 1h"), ActionID: ActionSnooze1h, Value: value},
+			Button{Type: "button", Text: *plainText("Snooze 4h"), ActionID: ActionSnooze4h, Value: value},
+			Button{Type: "button", Text: *plainText("Assign to me"), ActionID: ActionAssignToMe, Value: value},
+		)
+	}
+	blocks = append(blocks, Block{Type: "actions", Elements: buttons})
+
+	return blocks
+}
+
+// NewResolvedMessage renders the replacement for an alert whose ticket no
+// longer needs attention
+func NewResolvedMessage(n channel.Notification) Message {
+	text := FormatResolved(n)
+	lines := strings.SplitN(text, "\n", 2)
+
+	return Message{
+		Text: text,
+		Blocks: []Block{
+			{
+				Type: "section",
+				Text: &TextObject{Type: "mrkdwn", Text: lines[0]},
+			},
+			{
+				Type:     "context",
+				Elements: []interface{}{mrkdwn(fmt.Sprintf("%s · <%s|Open in FreeScout>", escape(n.Ticket.Subject), n.TicketURL))},
+			},
+		},
+	}
+}
+
+// NewBatchMessages renders a queued burst as messages of at most
+// batchSize tickets, each with a header and a section per ticket.
+// mention returns the mention of a ticket's assignee, or "".
+func NewBatchMessages(ns []channel.Notification, mention func(channel.Notification) string) []Message {
+	chunks := channel.Chunk(ns, batchSize)
+	messages := make([]Message, len(chunks))
+
+	for i, chunk := range chunks {
+		headline := channel.BatchHeadline(len(ns), i+1, len(chunks))
+		blocks := []Block{{Type: "header", Text: plainText(headline)}}
+		for _, n := range chunk {
+			text := fmt.Sprintf("*<%s|%s>*\n%s", n.TicketURL, escape(n.Headline()), escape(n.Summary()))
+			if m := mention(n); m != "" {
+				text += " " + m
+			}
+			blocks = append(blocks, Block{Type: "section", Text: &TextObject{Type: "mrkdwn", Text: text}})
+		}
+		messages[i] = Message{Text: headline, Blocks: blocks}
+	}
+
+	return messages
+}
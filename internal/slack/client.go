@@ -1,28 +1,62 @@
 package slack
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
-	"time"
+	"strings"
 
+	"github.com/voicetel/freescout-notifier/internal/channel"
 	"github.com/voicetel/freescout-notifier/internal/config"
+	"github.com/voicetel/freescout-notifier/internal/models"
 )
 
 type Client struct {
+	name          string
 	webhookURL    string
+	botToken      string
+	channel       string
+	apiURL        string
+	interactive   bool
+	mentions      map[int]string
+	store         MessageStore
 	httpClient    *http.Client
 	retryAttempts int
 }
 
+// MessageStore persists the Slack channel and timestamp of posted alerts so
+// reminders can be threaded under the original message
+type MessageStore interface {
+	GetSlackMessage(ticketID int, notificationType models.NotificationType, channelName string) (models.SlackMessage, error)
+	SaveSlackMessage(ticketID int, notificationType models.NotificationType, channelName string, message models.SlackMessage) error
+	DeleteSlackMessage(ticketID int, notificationType models.NotificationType, channelName string) error
+}
+
+// Message is the body posted to the webhook or the chat.postMessage and
+// chat.update methods. Text is the notification fallback when Blocks or
+// Attachments are set.
 type Message struct {
-	Text string `json:"text"`
+	Channel     string       `json:"channel,omitempty"`
+	Text        string       `json:"text"`
+	Blocks      []Block      `json:"blocks,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	ThreadTS    string       `json:"thread_ts,omitempty"`
+	TS          string       `json:"ts,omitempty"` // Message to replace in chat.update
 }
 
-func NewClient(cfg config.SlackConfig) *Client {
+// NewClient creates a Slack client. The store is only used in bot mode and
+// may be nil, in which case reminders are posted as new messages.
+func NewClient(name string, cfg config.SlackConfig, store MessageStore) *Client {
 	return &Client{
-		webhookURL: cfg.WebhookURL,
+		name:        name,
+		webhookURL:  cfg.WebhookURL,
+		botToken:    cfg.BotToken,
+		channel:     cfg.Channel,
+		apiURL:      strings.TrimSuffix(cfg.APIURL, "/"),
+		interactive: cfg.Interactive,
+		mentions:    cfg.UserMentions,
+		store:       store,
 		httpClient: &http.Client{
 			Timeout: cfg.Timeout.Duration,
 		},
@@ -30,40 +64,258 @@ func NewClient(cfg config.SlackConfig) *Client {
 	}
 }
 
-func (c *Client) SendMessage(text string) error {
-	message := Message{Text: text}
-	payload, err := json.Marshal(message)
-	if err != nil {
-		return fmt.Errorf("failed to marshal message: %w", err)
+// Name implements channel.Channel
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Send implements channel.Channel
+func (c *Client) Send(n channel.Notification) error {
+	if c.botToken != "" {
+		return c.sendThreaded(n)
 	}
+	return c.Post(c.alertMessage(n))
+}
+
+// SendBatch implements channel.BatchSender. The burst is posted as a few
+// messages listing the tickets. In bot mode later reminders for a ticket are
+// threaded under the message that listed it.
+func (c *Client) SendBatch(ns []channel.Notification) ([]bool, error) {
+	delivered := make([]bool, len(ns))
+	chunks := channel.Chunk(ns, batchSize)
 
-	var lastErr error
-	for attempt := 0; attempt < c.retryAttempts; attempt++ {
-		if attempt > 0 {
-			// Exponential backoff
-			time.Sleep(time.Duration(attempt*attempt) * time.Second)
+	var firstErr error
+	start := 0
+	for i, message := range NewBatchMessages(ns, c.mention) {
+		chunk := chunks[i]
+		if err := c.postBatch(message, chunk); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("message %d of %d: %w", i+1, len(chunks), err)
+			}
+		} else {
+			for j := range chunk {
+				delivered[start+j] = true
+			}
 		}
+		start += len(chunk)
+	}
+	return delivered, firstErr
+}
 
-		req, err := http.NewRequest("POST", c.webhookURL, bytes.NewBuffer(payload))
-		if err != nil {
-			return fmt.Errorf("failed to create request: %w", err)
+// postBatch posts one message of a queued burst and, in bot mode, records it
+// as the alert of each ticket it lists
+func (c *Client) postBatch(message Message, ns []channel.Notification) error {
+	if c.botToken == "" {
+		return c.Post(message)
+	}
+
+	message.Channel = c.channel
+	resp, err := c.PostMessage(message)
+	if err != nil {
+		return err
+	}
+
+	if c.store != nil {
+		ref := models.SlackMessage{Channel: resp.Channel, TS: resp.TS, Batched: true}
+		for _, n := range ns {
+			if err := c.store.SaveSlackMessage(n.Ticket.ID, n.Ticket.NotificationType, c.name, ref); err != nil {
+				log.Printf("Warning: failed to save Slack message reference for ticket #%d: %v", n.Ticket.ID, err)
+			}
 		}
+	}
+	return nil
+}
+
+// postNew posts a standalone message through the Web API in bot mode, or
+// the incoming webhook otherwise
+func (c *Client) postNew(message Message) error {
+	if c.botToken != "" {
+		message.Channel = c.channel
+		_, err := c.PostMessage(message)
+		return err
+	}
+	return c.Post(message)
+}
+
+// Resolve implements channel.Resolver. In bot mode the original alert is
+// rewritten to show the ticket was answered, or for a ticket listed in a
+// batched message a reply is posted in its thread; webhook messages cannot
+// be edited and are left unchanged.
+func (c *Client) Resolve(n channel.Notification) error {
+	if c.botToken == "" || c.store == nil {
+		return nil
+	}
 
-		req.Header.Set("Content-Type", "application/json")
+	ref, err := c.store.GetSlackMessage(n.Ticket.ID, n.Ticket.NotificationType, c.name)
+	if err != nil {
+		return fmt.Errorf("failed to look up previous message: %w", err)
+	}
+	if ref.TS == "" {
+		return nil
+	}
 
-		resp, err := c.httpClient.Do(req)
+	if ref.Batched {
+		// The message lists other tickets, so it is left unchanged
+		_, err = c.PostMessage(Message{Channel: ref.Channel, Text: FormatResolved(n), ThreadTS: ref.TS})
+	} else {
+		message := NewResolvedMessage(n)
+		message.Channel = ref.Channel
+		message.TS = ref.TS
+		_, err = c.UpdateMessage(message)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The next alert for this ticket starts a new thread
+	return c.store.DeleteSlackMessage(n.Ticket.ID, n.Ticket.NotificationType, c.name)
+}
+
+// Test implements channel.Tester
+func (c *Client) Test() error {
+	text := "🔧 FreeScout Notifier test message - connection successful!"
+	if c.botToken != "" {
+		_, err := c.PostMessage(Message{Channel: c.channel, Text: text})
+		return err
+	}
+	return c.SendMessage(text)
+}
+
+// sendThreaded posts the first alert for a ticket as a new message and any
+// later reminders as replies in its thread
+func (c *Client) sendThreaded(n channel.Notification) error {
+	var ref models.SlackMessage
+	if c.store != nil {
+		var err error
+		ref, err = c.store.GetSlackMessage(n.Ticket.ID, n.Ticket.NotificationType, c.name)
 		if err != nil {
-			lastErr = err
-			continue
+			return fmt.Errorf("failed to look up previous message: %w", err)
 		}
-		defer resp.Body.Close()
+	}
 
-		if resp.StatusCode == http.StatusOK {
-			return nil
+	if ref.TS != "" {
+		reminder := FormatReminder(n)
+		if ref.Batched {
+			// The thread is shared by the tickets of the batched message
+			reminder = fmt.Sprintf("*Ticket #%d:* %s", n.Ticket.ID, reminder)
 		}
+		_, err := c.PostMessage(Message{
+			Channel:  ref.Channel,
+			Text:     strings.TrimSpace(c.mention(n) + " " + reminder),
+			ThreadTS: ref.TS,
+		})
+		return err
+	}
+
+	message := c.alertMessage(n)
+	message.Channel = c.channel
+	resp, err := c.PostMessage(message)
+	if err != nil {
+		return err
+	}
+
+	// The alert was delivered, so a failure to store its reference only
+	// means later reminders start a new thread
+	if c.store != nil {
+		ref := models.SlackMessage{Channel: resp.Channel, TS: resp.TS}
+		if err := c.store.SaveSlackMessage(n.Ticket.ID, n.Ticket.NotificationType, c.name, ref); err != nil {
+			log.Printf("Warning: failed to save Slack message reference for ticket #%d: %v", n.Ticket.ID, err)
+		}
+	}
+	return nil
+}
 
-		lastErr = fmt.Errorf("slack webhook returned status %d", resp.StatusCode)
+// alertMessage renders an alert, mentioning the assigned agent below the
+// header when they are mapped in user_mentions
+func (c *Client) alertMessage(n channel.Notification) Message {
+	message := NewAlertMessage(n, c.interactive)
+
+	mention := c.mention(n)
+	if mention == "" {
+		return message
+	}
+
+	message.Text = mention + " " + message.Text
+	blocks := []Block{message.Blocks[0], {Type: "section", Text: &TextObject{Type: "mrkdwn", Text: "🔔 " + mention}}}
+	message.Blocks = append(blocks, message.Blocks[1:]...)
+	return message
+}
+
+// mention returns the Slack mention of the user who took the ticket with
+// Assign to me, or else of the ticket's assignee, or "" when the ticket is
+// unassigned or the assignee is not mapped
+func (c *Client) mention(n channel.Notification) string {
+	if n.Ticket.OwnerSlackID != "" {
+		return fmt.Sprintf("<@%s>", n.Ticket.OwnerSlackID)
+	}
+	if n.Ticket.AssignedUserID == nil {
+		return ""
+	}
+	if memberID := c.mentions[*n.Ticket.AssignedUserID]; memberID != "" {
+		return fmt.Sprintf("<@%s>", memberID)
+	}
+	return ""
+}
+
+// FormatReminder renders the thread reply posted when a ticket still needs
+// attention after the cooldown period
+func FormatReminder(n channel.Notification) string {
+	_, _, waitingFor := n.Describe()
+	return fmt.Sprintf("⏰ Still waiting for %s after %s (assigned to %s)", waitingFor, n.WaitingTime(), n.AssignedTo())
+}
+
+// FormatResolved renders the replacement text for an alert whose ticket no
+// longer needs attention
+func FormatResolved(n channel.Notification) string {
+	outcome := "answered"
+	switch n.Ticket.NotificationType {
+	case models.PendingNoCustomerResponse:
+		outcome = "customer responded"
+	case models.UnassignedConversation:
+		outcome = "assigned"
+	case models.ReturnedFromPending:
+		outcome = "picked up"
+	}
+
+	message := fmt.Sprintf("✅ Ticket #%d %s after %s\n", n.Ticket.ID, outcome, n.WaitingTime())
+	message += fmt.Sprintf("*Subject:* %s\n", n.Ticket.Subject)
+	message += fmt.Sprintf("*View ticket:* <%s|Open in FreeScout>", n.TicketURL)
+
+	return message
+}
+
+// FormatMessage renders a notification as Slack mrkdwn text, used as the
+// fallback for the Block Kit layout
+func FormatMessage(n channel.Notification) string {
+	_, _, waitingFor := n.Describe()
+
+	message := n.Headline() + "\n"
+	message += fmt.Sprintf("*Subject:* %s\n", n.Ticket.Subject)
+	message += fmt.Sprintf("*Customer:* %s\n", n.Ticket.CustomerName)
+	message += fmt.Sprintf("*Mailbox:* %s\n", n.Mailbox())
+	if len(n.Ticket.Tags) > 0 {
+		message += fmt.Sprintf("*Tags:* %s\n", strings.Join(n.Ticket.Tags, ", "))
+	}
+	message += fmt.Sprintf("*Waiting for:* %s for %s\n", waitingFor, n.WaitingTime())
+	message += fmt.Sprintf("*Assigned to:* %s\n", n.AssignedTo())
+	if n.TierName != "" {
+		message += fmt.Sprintf("*Escalation:* %s\n", n.TierName)
+	}
+	message += fmt.Sprintf("*View ticket:* <%s|Open in FreeScout>", n.TicketURL)
+
+	return message
+}
+
+func (c *Client) SendMessage(text string) error {
+	return c.Post(Message{Text: text})
+}
+
+// Post sends a message to the incoming webhook
+func (c *Client) Post(message Message) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
-	return fmt.Errorf("failed after %d attempts: %w", c.retryAttempts, lastErr)
+	return channel.PostJSON(c.httpClient, c.webhookURL, payload, nil, c.retryAttempts)
 }
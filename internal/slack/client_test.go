@@ -0,0 +1,277 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/voicetel/freescout-notifier/internal/channel"
+	"github.com/voicetel/freescout-notifier/internal/config"
+	"github.com/voicetel/freescout-notifier/internal/models"
+)
+
+// apiCall is a Web API request received by the fake API
+type apiCall struct {
+	method  string
+	message Message
+}
+
+// fakeAPI is a stand-in for the Slack Web API that records the messages it
+// receives. status returns the HTTP status of a request, counted from 1, and
+// fail lists the requests answered with ok false.
+type fakeAPI struct {
+	mu     sync.Mutex
+	calls  []apiCall
+	status func(n int) int
+	fail   map[int]bool
+}
+
+func newFakeAPI(t *testing.T) (*fakeAPI, *httptest.Server) {
+	t.Helper()
+
+	f := &fakeAPI{fail: make(map[int]bool)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xoxb-test" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		body, _ := io.ReadAll(r.Body)
+
+		var message Message
+		if err := json.Unmarshal(body, &message); err != nil {
+			t.Errorf("invalid message body %s: %v", body, err)
+		}
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.calls = append(f.calls, apiCall{method: strings.TrimPrefix(r.URL.Path, "/"), message: message})
+		n := len(f.calls)
+
+		if f.status != nil {
+			if status := f.status(n); status != http.StatusOK {
+				w.WriteHeader(status)
+				return
+			}
+		}
+		if f.fail[n] {
+			json.NewEncoder(w).Encode(APIResponse{Error: "channel_not_found"})
+			return
+		}
+		ts := message.TS
+		if ts == "" {
+			ts = fmt.Sprintf("1700000000.%06d", n)
+		}
+		json.NewEncoder(w).Encode(APIResponse{OK: true, Channel: "C0TEST", TS: ts})
+	}))
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+// memoryStore is an in-memory MessageStore
+type memoryStore map[string]models.SlackMessage
+
+func storeKey(ticketID int, notificationType models.NotificationType, channelName string) string {
+	return fmt.Sprintf("%d/%s/%s", ticketID, notificationType, channelName)
+}
+
+func (s memoryStore) GetSlackMessage(ticketID int, notificationType models.NotificationType, channelName string) (models.SlackMessage, error) {
+	return s[storeKey(ticketID, notificationType, channelName)], nil
+}
+
+func (s memoryStore) SaveSlackMessage(ticketID int, notificationType models.NotificationType, channelName string, message models.SlackMessage) error {
+	s[storeKey(ticketID, notificationType, channelName)] = message
+	return nil
+}
+
+func (s memoryStore) DeleteSlackMessage(ticketID int, notificationType models.NotificationType, channelName string) error {
+	delete(s, storeKey(ticketID, notificationType, channelName))
+	return nil
+}
+
+func newBotClient(server *httptest.Server, store MessageStore, retryAttempts int) *Client {
+	return NewClient("slack", config.SlackConfig{
+		BotToken:      "xoxb-test",
+		Channel:       "C0TEST",
+		APIURL:        server.URL + "/",
+		RetryAttempts: retryAttempts,
+	}, store)
+}
+
+func testNotification(id int) channel.Notification {
+	return channel.Notification{
+		Ticket: models.Ticket{
+			ID:                id,
+			Subject:           fmt.Sprintf("Ticket subject %d", id),
+			CustomerName:      "Jane Doe",
+			AssignedUserName:  "John Smith",
+			MailboxName:       "Support",
+			MinutesSinceReply: 150,
+			NotificationType:  models.OpenNoAgentResponse,
+		},
+		Status:    models.StatusSent,
+		TicketURL: fmt.Sprintf("https://support.example.com/conversation/%d", id),
+	}
+}
+
+func testNotifications(count int) []channel.Notification {
+	ns := make([]channel.Notification, count)
+	for i := range ns {
+		ns[i] = testNotification(i + 1)
+	}
+	return ns
+}
+
+func TestSendThreadsReminders(t *testing.T) {
+	fake, server := newFakeAPI(t)
+	store := memoryStore{}
+	c := newBotClient(server, store, 1)
+	n := testNotification(42)
+
+	// The first alert starts a thread and its reference is saved
+	if err := c.Send(n); err != nil {
+		t.Fatalf("Send alert: %v", err)
+	}
+	ref := store[storeKey(42, models.OpenNoAgentResponse, "slack")]
+	if ref != (models.SlackMessage{Channel: "C0TEST", TS: "1700000000.000001"}) {
+		t.Fatalf("saved reference = %+v", ref)
+	}
+
+	// The reminder is a reply in that thread
+	if err := c.Send(n); err != nil {
+		t.Fatalf("Send reminder: %v", err)
+	}
+
+	// The resolution rewrites the alert and forgets the thread
+	if err := c.Resolve(n); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if _, ok := store[storeKey(42, models.OpenNoAgentResponse, "slack")]; ok {
+		t.Error("reference kept after Resolve")
+	}
+
+	if len(fake.calls) != 3 {
+		t.Fatalf("received %d calls, want 3", len(fake.calls))
+	}
+	alert, reminder, resolved := fake.calls[0], fake.calls[1], fake.calls[2]
+
+	if alert.method != "chat.postMessage" || alert.message.Channel != "C0TEST" || alert.message.ThreadTS != "" || len(alert.message.Blocks) == 0 {
+		t.Errorf("alert = %s %+v, want a new message with blocks", alert.method, alert.message)
+	}
+	if reminder.method != "chat.postMessage" || reminder.message.ThreadTS != ref.TS || reminder.message.Channel != ref.Channel {
+		t.Errorf("reminder = %s %+v, want a reply in thread %s", reminder.method, reminder.message, ref.TS)
+	}
+	if !strings.HasPrefix(reminder.message.Text, "⏰ Still waiting for agent response") {
+		t.Errorf("reminder text = %q", reminder.message.Text)
+	}
+	if resolved.method != "chat.update" || resolved.message.TS != ref.TS || !strings.HasPrefix(resolved.message.Text, "✅ Ticket #42 answered") {
+		t.Errorf("resolution = %s %+v, want chat.update of %s", resolved.method, resolved.message, ref.TS)
+	}
+}
+
+func TestSendBatchThreadsReminders(t *testing.T) {
+	fake, server := newFakeAPI(t)
+	fake.fail[2] = true
+	store := memoryStore{}
+	c := newBotClient(server, store, 1)
+
+	delivered, err := c.SendBatch(testNotifications(45))
+	if err == nil || !strings.Contains(err.Error(), "message 2 of 3") {
+		t.Errorf("SendBatch error = %v, want it to name message 2 of 3", err)
+	}
+	if len(fake.calls) != 3 {
+		t.Fatalf("received %d calls, want 3", len(fake.calls))
+	}
+
+	// Every ticket of a posted message refers to it, tickets of the failed
+	// message are not delivered and start their own thread later
+	for i, ok := range delivered {
+		id := i + 1
+		ref := store[storeKey(id, models.OpenNoAgentResponse, "slack")]
+		want := models.SlackMessage{}
+		switch {
+		case id <= 20:
+			want = models.SlackMessage{Channel: "C0TEST", TS: "1700000000.000001", Batched: true}
+		case id > 40:
+			want = models.SlackMessage{Channel: "C0TEST", TS: "1700000000.000003", Batched: true}
+		}
+		if ok != (want.TS != "") || ref != want {
+			t.Errorf("ticket #%d delivered = %t, reference = %+v, want %+v", id, ok, ref, want)
+		}
+	}
+
+	// A reminder in the shared thread names its ticket
+	if err := c.Send(testNotification(7)); err != nil {
+		t.Fatalf("Send reminder: %v", err)
+	}
+	reminder := fake.calls[3]
+	if reminder.message.ThreadTS != "1700000000.000001" || !strings.HasPrefix(reminder.message.Text, "*Ticket #7:* ⏰ Still waiting") {
+		t.Errorf("reminder = %+v, want a reply prefixed with the ticket", reminder.message)
+	}
+
+	// The shared message is left as is and the resolution is a reply
+	if err := c.Resolve(testNotification(45)); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	resolved := fake.calls[4]
+	if resolved.method != "chat.postMessage" || resolved.message.ThreadTS != "1700000000.000003" ||
+		!strings.HasPrefix(resolved.message.Text, "✅ Ticket #45 answered") {
+		t.Errorf("resolution = %s %+v, want a reply in thread 1700000000.000003", resolved.method, resolved.message)
+	}
+	if _, ok := store[storeKey(45, models.OpenNoAgentResponse, "slack")]; ok {
+		t.Error("reference kept after Resolve")
+	}
+}
+
+func TestCallRetries(t *testing.T) {
+	tests := []struct {
+		status    int
+		wantCalls int
+		wantErr   bool
+	}{
+		{http.StatusBadRequest, 1, true},
+		{http.StatusUnauthorized, 1, true},
+		{http.StatusNotFound, 1, true},
+		{http.StatusTooManyRequests, 2, false},
+		{http.StatusBadGateway, 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.status), func(t *testing.T) {
+			fake, server := newFakeAPI(t)
+			// Only the first attempt fails
+			fake.status = func(n int) int {
+				if n == 1 {
+					return tt.status
+				}
+				return http.StatusOK
+			}
+			c := newBotClient(server, nil, 2)
+
+			_, err := c.PostMessage(Message{Channel: "C0TEST", Text: "test"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PostMessage error = %v, want error %t", err, tt.wantErr)
+			}
+			if len(fake.calls) != tt.wantCalls {
+				t.Errorf("received %d calls, want %d", len(fake.calls), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCallAPIErrorNotRetried(t *testing.T) {
+	fake, server := newFakeAPI(t)
+	fake.fail[1] = true
+	c := newBotClient(server, nil, 3)
+
+	_, err := c.PostMessage(Message{Channel: "C0TEST", Text: "test"})
+	if err == nil || !strings.Contains(err.Error(), "channel_not_found") {
+		t.Errorf("PostMessage error = %v, want channel_not_found", err)
+	}
+	if len(fake.calls) != 1 {
+		t.Errorf("received %d calls, want 1", len(fake.calls))
+	}
+}
@@ -0,0 +1,85 @@
+package slack
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/voicetel/freescout-notifier/internal/channel"
+)
+
+// Slack rejects section text over 3000 characters and messages with more
+// than 50 blocks
+const (
+	maxSectionText  = 3000
+	maxDigestGroups = 45
+)
+
+// SendDigest implements channel.DigestSender
+func (c *Client) SendDigest(d channel.Digest) error {
+	return c.postNew(NewDigestMessage(d, time.Now()))
+}
+
+// NewDigestMessage renders a digest with a section per assignee listing
+// their tickets and a context line with the totals per mailbox
+func NewDigestMessage(d channel.Digest, now time.Time) Message {
+	blocks := []Block{{Type: "header", Text: plainText(d.Headline())}}
+
+	groups := d.ByAssignee()
+	for i, g := range groups {
+		if i == maxDigestGroups {
+			blocks = append(blocks, Block{
+				Type: "section",
+				Text: &TextObject{Type: "mrkdwn", Text: fmt.Sprintf("_…and %d more assignees_", len(groups)-i)},
+			})
+			break
+		}
+		blocks = append(blocks, Block{
+			Type: "section",
+			Text: &TextObject{Type: "mrkdwn", Text: formatDigestGroup(g, now)},
+		})
+	}
+
+	if mailboxes := d.ByMailbox(); len(mailboxes) > 0 {
+		summaries := make([]string, len(mailboxes))
+		for i, g := range mailboxes {
+			summaries[i] = escape(g.Summary())
+		}
+		blocks = append(blocks, Block{
+			Type:     "context",
+			Elements: []interface{}{mrkdwn(truncate("*By mailbox:* "+strings.Join(summaries, " · "), maxSectionText))},
+		})
+	}
+
+	return Message{
+		Text:   d.Headline(),
+		Blocks: blocks,
+	}
+}
+
+// formatDigestGroup renders an assignee's tickets as a bulleted list,
+// linking each ticket to FreeScout
+func formatDigestGroup(g channel.DigestGroup, now time.Time) string {
+	text := fmt.Sprintf("*%s*", escape(g.Summary()))
+	for i, t := range g.Tickets {
+		line := fmt.Sprintf("\n• <%s|%s>", t.TicketURL, escape(t.Line(now)))
+		if len(text)+len(line) > maxSectionText-50 {
+			return text + fmt.Sprintf("\n_…and %d more_", len(g.Tickets)-i)
+		}
+		text += line
+	}
+	return text
+}
+
+// truncate shortens s to at most max bytes without splitting a character
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	cut := max - 3
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "..."
+}
@@ -0,0 +1,262 @@
+package teams
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/voicetel/freescout-notifier/internal/channel"
+	"github.com/voicetel/freescout-notifier/internal/config"
+	"github.com/voicetel/freescout-notifier/internal/models"
+)
+
+// Client posts notifications to a Microsoft Teams incoming webhook as
+// Adaptive Cards
+type Client struct {
+	name          string
+	webhookURL    string
+	httpClient    *http.Client
+	retryAttempts int
+}
+
+// batchSize is the number of tickets per card of a queued burst, keeping
+// each card well under the webhook's payload size limit
+const batchSize = 25
+
+// Message is the envelope Teams expects for card attachments
+type Message struct {
+	Type        string       `json:"type"`
+	Attachments []Attachment `json:"attachments"`
+}
+
+type Attachment struct {
+	ContentType string       `json:"contentType"`
+	Content     AdaptiveCard `json:"content"`
+}
+
+type AdaptiveCard struct {
+	Schema  string    `json:"$schema"`
+	Type    string    `json:"type"`
+	Version string    `json:"version"`
+	Body    []Element `json:"body"`
+	Actions []Action  `json:"actions,omitempty"`
+}
+
+// Element is an Adaptive Card body element. Only the fields used by the
+// TextBlock and FactSet element types are modelled.
+type Element struct {
+	Type   string `json:"type"`
+	Text   string `json:"text,omitempty"`
+	Weight string `json:"weight,omitempty"`
+	Size   string `json:"size,omitempty"`
+	Color  string `json:"color,omitempty"`
+	Wrap   bool   `json:"wrap,omitempty"`
+	Facts  []Fact `json:"facts,omitempty"`
+}
+
+type Fact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type Action struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+func NewClient(name string, cfg config.TeamsConfig) *Client {
+	return &Client{
+		name:       name,
+		webhookURL: cfg.WebhookURL,
+		httpClient: &http.Client{
+			Timeout: cfg.Timeout.Duration,
+		},
+		retryAttempts: cfg.RetryAttempts,
+	}
+}
+
+// Name implements channel.Channel
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Send implements channel.Channel
+func (c *Client) Send(n channel.Notification) error {
+	return c.SendCard(FormatCard(n))
+}
+
+// Test implements channel.Tester
+func (c *Client) Test() error {
+	return c.SendCard(newCard([]Element{{
+		Type: "TextBlock",
+		Text: "🔧 FreeScout Notifier test message - connection successful!",
+		Wrap: true,
+	}}, nil))
+}
+
+// FormatCard renders a notification as an Adaptive Card with the same
+// fields as the Slack message
+func FormatCard(n channel.Notification) AdaptiveCard {
+	_, _, waitingFor := n.Describe()
+
+	color := "Attention"
+	if n.Ticket.NotificationType != models.OpenNoAgentResponse && !n.Ticket.VIP {
+		color = "Warning"
+	}
+
+	facts := []Fact{
+		{Title: "Subject", Value: n.Ticket.Subject},
+		{Title: "Customer", Value: n.Ticket.CustomerName},
+		{Title: "Mailbox", Value: n.Mailbox()},
+		{Title: "Waiting for", Value: fmt.Sprintf("%s for %s", waitingFor, n.WaitingTime())},
+		{Title: "Assigned to", Value: n.AssignedTo()},
+	}
+	if len(n.Ticket.Tags) > 0 {
+		facts = append(facts, Fact{Title: "Tags", Value: strings.Join(n.Ticket.Tags, ", ")})
+	}
+	if n.TierName != "" {
+		facts = append(facts, Fact{Title: "Escalation", Value: n.TierName})
+	}
+
+	return newCard([]Element{
+		{
+			Type:   "TextBlock",
+			Text:   n.Headline(),
+			Weight: "Bolder",
+			Size:   "Medium",
+			Color:  color,
+			Wrap:   true,
+		},
+		{
+			Type:  "FactSet",
+			Facts: facts,
+		},
+	}, []Action{
+		{Type: "Action.OpenUrl", Title: "Open in FreeScout", URL: n.TicketURL},
+	})
+}
+
+// SendBatch implements channel.BatchSender. The burst is posted as a few
+// cards listing the tickets.
+func (c *Client) SendBatch(ns []channel.Notification) ([]bool, error) {
+	delivered := make([]bool, len(ns))
+	chunks := channel.Chunk(ns, batchSize)
+
+	var firstErr error
+	start := 0
+	for i, card := range FormatBatchCards(ns) {
+		if err := c.SendCard(card); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("card %d of %d: %w", i+1, len(chunks), err)
+			}
+		} else {
+			for j := range chunks[i] {
+				delivered[start+j] = true
+			}
+		}
+		start += len(chunks[i])
+	}
+	return delivered, firstErr
+}
+
+// FormatBatchCards renders a queued burst as cards of at most batchSize
+// tickets, each ticket linking to FreeScout
+func FormatBatchCards(ns []channel.Notification) []AdaptiveCard {
+	chunks := channel.Chunk(ns, batchSize)
+	cards := make([]AdaptiveCard, len(chunks))
+
+	for i, chunk := range chunks {
+		body := []Element{{
+			Type:   "TextBlock",
+			Text:   channel.BatchHeadline(len(ns), i+1, len(chunks)),
+			Weight: "Bolder",
+			Size:   "Medium",
+			Wrap:   true,
+		}}
+		for _, n := range chunk {
+			body = append(body, Element{
+				Type: "TextBlock",
+				Text: fmt.Sprintf("**[%s](%s)**\n\n%s", n.Headline(), n.TicketURL, n.Summary()),
+				Wrap: true,
+			})
+		}
+		cards[i] = newCard(body, nil)
+	}
+
+	return cards
+}
+
+// SendDigest implements channel.DigestSender
+func (c *Client) SendDigest(d channel.Digest) error {
+	return c.SendCard(FormatDigestCard(d, time.Now()))
+}
+
+// FormatDigestCard renders a digest as an Adaptive Card with a list of
+// tickets per assignee and a fact set of the totals per mailbox
+func FormatDigestCard(d channel.Digest, now time.Time) AdaptiveCard {
+	body := []Element{{
+		Type:   "TextBlock",
+		Text:   d.Headline(),
+		Weight: "Bolder",
+		Size:   "Medium",
+		Wrap:   true,
+	}}
+
+	for _, g := range d.ByAssignee() {
+		lines := make([]string, len(g.Tickets))
+		for i, t := range g.Tickets {
+			lines[i] = fmt.Sprintf("- [%s](%s)", t.Line(now), t.TicketURL)
+		}
+		body = append(body,
+			Element{Type: "TextBlock", Text: g.Summary(), Weight: "Bolder", Wrap: true},
+			Element{Type: "TextBlock", Text: strings.Join(lines, "\n"), Wrap: true},
+		)
+	}
+
+	if mailboxes := d.ByMailbox(); len(mailboxes) > 0 {
+		facts := make([]Fact, len(mailboxes))
+		for i, g := range mailboxes {
+			facts[i] = Fact{
+				Title: g.Name,
+				Value: fmt.Sprintf("%d, oldest waiting %s", len(g.Tickets), channel.FormatDuration(g.Oldest)),
+			}
+		}
+		body = append(body,
+			Element{Type: "TextBlock", Text: "By mailbox", Weight: "Bolder", Wrap: true},
+			Element{Type: "FactSet", Facts: facts},
+		)
+	}
+
+	return newCard(body, nil)
+}
+
+func newCard(body []Element, actions []Action) AdaptiveCard {
+	return AdaptiveCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+		Body:    body,
+		Actions: actions,
+	}
+}
+
+// SendCard posts a single Adaptive Card to the webhook
+func (c *Client) SendCard(card AdaptiveCard) error {
+	message := Message{
+		Type: "message",
+		Attachments: []Attachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content:     card,
+		}},
+	}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	return channel.PostJSON(c.httpClient, c.webhookURL, payload, nil, c.retryAttempts)
+}
@@ -0,0 +1,272 @@
+package teams
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/voicetel/freescout-notifier/internal/channel"
+	"github.com/voicetel/freescout-notifier/internal/config"
+	"github.com/voicetel/freescout-notifier/internal/models"
+)
+
+func testNotification(id int) channel.Notification {
+	return channel.Notification{
+		Ticket: models.Ticket{
+			ID:                id,
+			Subject:           fmt.Sprintf("Ticket subject %d", id),
+			CustomerName:      "Jane Doe",
+			AssignedUserName:  "John Smith",
+			MailboxID:         1,
+			MailboxName:       "Support",
+			MinutesSinceReply: 150,
+			NotificationType:  models.OpenNoAgentResponse,
+		},
+		Status:    models.StatusSent,
+		TicketURL: fmt.Sprintf("https://support.example.com/conversation/%d", id),
+	}
+}
+
+func testNotifications(count int) []channel.Notification {
+	ns := make([]channel.Notification, count)
+	for i := range ns {
+		ns[i] = testNotification(i + 1)
+	}
+	return ns
+}
+
+// fakeWebhook is a stand-in for a Teams incoming webhook that records the
+// cards it receives and fails the requests listed in fail, counted from 1
+type fakeWebhook struct {
+	mu    sync.Mutex
+	cards []AdaptiveCard
+	fail  map[int]bool
+}
+
+func newFakeWebhook(t *testing.T, fail ...int) (*fakeWebhook, *httptest.Server) {
+	t.Helper()
+
+	f := &fakeWebhook{fail: make(map[int]bool)}
+	for _, n := range fail {
+		f.fail[n] = true
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		var message Message
+		if err := json.Unmarshal(body, &message); err != nil {
+			t.Errorf("invalid message body %s: %v", body, err)
+		}
+		if message.Type != "message" || len(message.Attachments) != 1 ||
+			message.Attachments[0].ContentType != "application/vnd.microsoft.card.adaptive" {
+			t.Errorf("unexpected envelope %s", body)
+		}
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.cards = append(f.cards, message.Attachments[0].Content)
+		if f.fail[len(f.cards)] {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func TestFormatCard(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(n *channel.Notification)
+		wantTitle string
+		wantColor string
+		wantFacts []Fact
+	}{
+		{
+			name:      "open ticket",
+			modify:    func(n *channel.Notification) {},
+			wantTitle: "🚨 Ticket #1 needs attention",
+			wantColor: "Attention",
+			wantFacts: []Fact{
+				{Title: "Subject", Value: "Ticket subject 1"},
+				{Title: "Customer", Value: "Jane Doe"},
+				{Title: "Mailbox", Value: "Support"},
+				{Title: "Waiting for", Value: "agent response for 2 hours 30 minutes"},
+				{Title: "Assigned to", Value: "John Smith"},
+			},
+		},
+		{
+			name: "pending ticket with tags and tier",
+			modify: func(n *channel.Notification) {
+				n.Ticket.NotificationType = models.PendingNoCustomerResponse
+				n.Ticket.Tags = []string{"billing", "refund"}
+				n.Ticket.AssignedUserName = ""
+				n.Ticket.OwnerName = "jane"
+				n.TierName = "Managers"
+			},
+			wantTitle: "⏳ Ticket #1 waiting for customer",
+			wantColor: "Warning",
+			wantFacts: []Fact{
+				{Title: "Subject", Value: "Ticket subject 1"},
+				{Title: "Customer", Value: "Jane Doe"},
+				{Title: "Mailbox", Value: "Support"},
+				{Title: "Waiting for", Value: "customer response for 2 hours 30 minutes"},
+				{Title: "Assigned to", Value: "Unassigned (owner: jane)"},
+				{Title: "Tags", Value: "billing, refund"},
+				{Title: "Escalation", Value: "Managers"},
+			},
+		},
+		{
+			name: "VIP pending ticket",
+			modify: func(n *channel.Notification) {
+				n.Ticket.NotificationType = models.PendingNoCustomerResponse
+				n.Ticket.VIP = true
+			},
+			wantColor: "Attention",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := testNotification(1)
+			tt.modify(&n)
+			card := FormatCard(n)
+
+			if card.Type != "AdaptiveCard" || card.Version != "1.4" {
+				t.Errorf("card = %s %s, want AdaptiveCard 1.4", card.Type, card.Version)
+			}
+			if len(card.Body) != 2 {
+				t.Fatalf("card has %d body elements, want 2", len(card.Body))
+			}
+			title := card.Body[0]
+			if title.Color != tt.wantColor {
+				t.Errorf("title color = %s, want %s", title.Color, tt.wantColor)
+			}
+			if tt.wantTitle != "" && title.Text != tt.wantTitle {
+				t.Errorf("title = %q, want %q", title.Text, tt.wantTitle)
+			}
+			if tt.wantFacts != nil && !reflect.DeepEqual(card.Body[1].Facts, tt.wantFacts) {
+				t.Errorf("facts = %+v, want %+v", card.Body[1].Facts, tt.wantFacts)
+			}
+			wantActions := []Action{{Type: "Action.OpenUrl", Title: "Open in FreeScout", URL: n.TicketURL}}
+			if !reflect.DeepEqual(card.Actions, wantActions) {
+				t.Errorf("actions = %+v, want %+v", card.Actions, wantActions)
+			}
+		})
+	}
+}
+
+func TestFormatBatchCards(t *testing.T) {
+	tests := []struct {
+		count         int
+		wantSizes     []int
+		wantHeadlines []string
+	}{
+		{1, []int{1}, []string{"🌅 1 ticket waiting since business hours closed"}},
+		{25, []int{25}, []string{"🌅 25 tickets waiting since business hours closed"}},
+		{26, []int{25, 1}, []string{
+			"🌅 26 tickets waiting since business hours closed (1/2)",
+			"🌅 26 tickets waiting since business hours closed (2/2)",
+		}},
+		{60, []int{25, 25, 10}, []string{
+			"🌅 60 tickets waiting since business hours closed (1/3)",
+			"🌅 60 tickets waiting since business hours closed (2/3)",
+			"🌅 60 tickets waiting since business hours closed (3/3)",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.count), func(t *testing.T) {
+			cards := FormatBatchCards(testNotifications(tt.count))
+			if len(cards) != len(tt.wantSizes) {
+				t.Fatalf("got %d cards, want %d", len(cards), len(tt.wantSizes))
+			}
+
+			next := 1
+			for i, card := range cards {
+				if card.Body[0].Text != tt.wantHeadlines[i] {
+					t.Errorf("card %d headline = %q, want %q", i+1, card.Body[0].Text, tt.wantHeadlines[i])
+				}
+				tickets := card.Body[1:]
+				if len(tickets) != tt.wantSizes[i] {
+					t.Errorf("card %d lists %d tickets, want %d", i+1, len(tickets), tt.wantSizes[i])
+				}
+				// Every ticket is listed once, in order, with its link
+				for _, e := range tickets {
+					link := fmt.Sprintf("(https://support.example.com/conversation/%d)", next)
+					if !strings.Contains(e.Text, link) {
+						t.Errorf("card %d element %q, want link %s", i+1, e.Text, link)
+					}
+					next++
+				}
+			}
+		})
+	}
+}
+
+func TestSend(t *testing.T) {
+	fake, server := newFakeWebhook(t)
+	c := NewClient("teams", config.TeamsConfig{WebhookURL: server.URL})
+
+	n := testNotification(7)
+	if err := c.Send(n); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if len(fake.cards) != 1 || !reflect.DeepEqual(fake.cards[0], FormatCard(n)) {
+		t.Errorf("received cards %+v, want the card of ticket #7", fake.cards)
+	}
+}
+
+func TestSendBatch(t *testing.T) {
+	tests := []struct {
+		name          string
+		count         int
+		fail          []int
+		wantDelivered []int // Number of delivered notifications per card, 0 when it failed
+		wantErr       string
+	}{
+		{"all delivered", 60, nil, []int{25, 25, 10}, ""},
+		{"second card fails", 60, []int{2}, []int{25, 0, 10}, "card 2 of 3"},
+		{"first and last cards fail", 60, []int{1, 3}, []int{0, 25, 0}, "card 1 of 3"},
+		{"single card fails", 5, []int{1}, []int{0}, "card 1 of 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, server := newFakeWebhook(t, tt.fail...)
+			c := NewClient("teams", config.TeamsConfig{WebhookURL: server.URL, RetryAttempts: 1})
+
+			delivered, err := c.SendBatch(testNotifications(tt.count))
+
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("SendBatch error = %v, want nil", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("SendBatch error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			// A failed card does not stop the cards after it
+			if len(fake.cards) != len(tt.wantDelivered) {
+				t.Errorf("posted %d cards, want %d", len(fake.cards), len(tt.wantDelivered))
+			}
+
+			var want []bool
+			for i, count := range tt.wantDelivered {
+				size := batchSize
+				if i == len(tt.wantDelivered)-1 {
+					size = tt.count - i*batchSize
+				}
+				for j := 0; j < size; j++ {
+					want = append(want, count > 0)
+				}
+			}
+			if !reflect.DeepEqual(delivered, want) {
+				t.Errorf("delivered = %v, want %v", delivered, want)
+			}
+		})
+	}
+}
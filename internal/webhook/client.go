@@ -0,0 +1,261 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/voicetel/freescout-notifier/internal/channel"
+	"github.com/voicetel/freescout-notifier/internal/config"
+)
+
+// PayloadVersion is incremented whenever a field is removed or changes
+// meaning. New fields may be added without a version bump.
+const PayloadVersion = 1
+
+// Event names sent in Payload.Event
+const (
+	EventNotification = "ticket.notification"
+	EventResolved     = "ticket.resolved"
+	EventDigest       = "digest"
+	EventTest         = "test"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of "v1:<timestamp>:<body>",
+// keyed with the configured secret and prefixed with "sha256="
+const SignatureHeader = "X-FreeScout-Notifier-Signature"
+
+// TimestampHeader carries the Unix time the request was signed at, so
+// receivers can reject replayed requests
+const TimestampHeader = "X-FreeScout-Notifier-Timestamp"
+
+// Client posts notifications as signed JSON documents to an arbitrary URL
+type Client struct {
+	name          string
+	url           string
+	secret        []byte
+	headers       map[string]string
+	httpClient    *http.Client
+	retryAttempts int
+	now           func() time.Time
+}
+
+// Payload is the JSON document posted for every event
+type Payload struct {
+	Version      int                  `json:"version"`
+	Event        string               `json:"event"`
+	Timestamp    time.Time            `json:"timestamp"`
+	Notification *NotificationPayload `json:"notification,omitempty"`
+	Ticket       *TicketPayload       `json:"ticket,omitempty"`
+	Digest       *DigestPayload       `json:"digest,omitempty"`
+}
+
+type NotificationPayload struct {
+	Type           string `json:"type"`
+	Status         string `json:"status"`
+	EscalationTier int    `json:"escalation_tier,omitempty"`
+	EscalationName string `json:"escalation_name,omitempty"`
+	Tone           string `json:"tone,omitempty"`
+}
+
+type TicketPayload struct {
+	ID                int       `json:"id"`
+	Number            int       `json:"number"`
+	Subject           string    `json:"subject"`
+	CustomerEmail     string    `json:"customer_email"`
+	CustomerName      string    `json:"customer_name"`
+	AssignedUserID    *int      `json:"assigned_user_id"`
+	AssignedUserName  string    `json:"assigned_user_name"`
+	OwnerName         string    `json:"owner_name"`
+	MailboxID         int       `json:"mailbox_id"`
+	MailboxName       string    `json:"mailbox_name"`
+	Tags              []string  `json:"tags"`
+	VIP               bool      `json:"vip"`
+	Priority          bool      `json:"priority"`
+	LastReplyAt       time.Time `json:"last_reply_at"`
+	MinutesSinceReply int       `json:"minutes_since_reply"`
+	URL               string    `json:"url"`
+}
+
+// DigestPayload summarizes every overdue ticket, grouped by assignee and
+// by mailbox
+type DigestPayload struct {
+	Title     string         `json:"title"`
+	Total     int            `json:"total"`
+	Assignees []GroupPayload `json:"assignees"`
+	Mailboxes []GroupPayload `json:"mailboxes"`
+}
+
+// GroupPayload is the tickets of one assignee or mailbox in a digest. Only
+// the assignee groups list their tickets.
+type GroupPayload struct {
+	Name          string                `json:"name"`
+	Count         int                   `json:"count"`
+	OldestMinutes int                   `json:"oldest_minutes"`
+	Tickets       []DigestTicketPayload `json:"tickets,omitempty"`
+}
+
+type DigestTicketPayload struct {
+	TicketPayload
+	Type          string     `json:"type"`
+	LastAlertedAt *time.Time `json:"last_alerted_at"`
+}
+
+func NewClient(name string, cfg config.WebhookConfig) *Client {
+	return &Client{
+		name:    name,
+		url:     cfg.URL,
+		secret:  []byte(cfg.Secret),
+		headers: cfg.Headers,
+		httpClient: &http.Client{
+			Timeout: cfg.Timeout.Duration,
+		},
+		retryAttempts: cfg.RetryAttempts,
+		now:           time.Now,
+	}
+}
+
+// Name implements channel.Channel
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Send implements channel.Channel
+func (c *Client) Send(n channel.Notification) error {
+	return c.Post(NewPayload(EventNotification, n))
+}
+
+// Resolve implements channel.Resolver
+func (c *Client) Resolve(n channel.Notification) error {
+	return c.Post(NewPayload(EventResolved, n))
+}
+
+// SendDigest implements channel.DigestSender
+func (c *Client) SendDigest(d channel.Digest) error {
+	return c.Post(NewDigestPayload(d))
+}
+
+// Test implements channel.Tester
+func (c *Client) Test() error {
+	return c.Post(Payload{
+		Version:   PayloadVersion,
+		Event:     EventTest,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// NewPayload builds the payload describing a notification
+func NewPayload(event string, n channel.Notification) Payload {
+	t := n.Ticket
+	return Payload{
+		Version:   PayloadVersion,
+		Event:     event,
+		Timestamp: time.Now().UTC(),
+		Notification: &NotificationPayload{
+			Type:           string(t.NotificationType),
+			Status:         string(n.Status),
+			EscalationTier: t.EscalationTier,
+			EscalationName: n.TierName,
+			Tone:           string(n.Tone),
+		},
+		Ticket: newTicketPayload(n),
+	}
+}
+
+// NewDigestPayload builds the payload describing a digest
+func NewDigestPayload(d channel.Digest) Payload {
+	digest := &DigestPayload{
+		Title:     d.Title,
+		Total:     len(d.Tickets),
+		Assignees: newGroupPayloads(d.ByAssignee(), true),
+		Mailboxes: newGroupPayloads(d.ByMailbox(), false),
+	}
+	return Payload{
+		Version:   PayloadVersion,
+		Event:     EventDigest,
+		Timestamp: time.Now().UTC(),
+		Digest:    digest,
+	}
+}
+
+func newGroupPayloads(groups []channel.DigestGroup, withTickets bool) []GroupPayload {
+	payloads := make([]GroupPayload, len(groups))
+	for i, g := range groups {
+		payloads[i] = GroupPayload{
+			Name:          g.Name,
+			Count:         len(g.Tickets),
+			OldestMinutes: int(g.Oldest.Minutes()),
+		}
+		if !withTickets {
+			continue
+		}
+		for _, t := range g.Tickets {
+			payloads[i].Tickets = append(payloads[i].Tickets, DigestTicketPayload{
+				TicketPayload: *newTicketPayload(t.Notification),
+				Type:          string(t.Ticket.NotificationType),
+				LastAlertedAt: t.LastAlertedAt,
+			})
+		}
+	}
+	return payloads
+}
+
+func newTicketPayload(n channel.Notification) *TicketPayload {
+	t := n.Ticket
+	tags := t.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return &TicketPayload{
+		ID:                t.ID,
+		Number:            t.Number,
+		Subject:           t.Subject,
+		CustomerEmail:     t.CustomerEmail,
+		CustomerName:      t.CustomerName,
+		AssignedUserID:    t.AssignedUserID,
+		AssignedUserName:  strings.TrimSpace(t.AssignedUserName),
+		OwnerName:         t.OwnerName,
+		MailboxID:         t.MailboxID,
+		MailboxName:       t.MailboxName,
+		Tags:              tags,
+		VIP:               t.VIP,
+		Priority:          t.Priority,
+		LastReplyAt:       t.LastReplyAt,
+		MinutesSinceReply: t.MinutesSinceReply,
+		URL:               n.TicketURL,
+	}
+}
+
+// Post signs and delivers a payload
+func (c *Client) Post(p Payload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	headers := make(map[string]string, len(c.headers)+2)
+	for key, value := range c.headers {
+		headers[key] = value
+	}
+	if len(c.secret) > 0 {
+		timestamp := strconv.FormatInt(c.now().Unix(), 10)
+		headers[TimestampHeader] = timestamp
+		headers[SignatureHeader] = Sign(c.secret, timestamp, body)
+	}
+
+	return channel.PostJSON(c.httpClient, c.url, body, headers, c.retryAttempts)
+}
+
+// Sign returns the signature header value for a body sent at timestamp
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "v1:%s:", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/voicetel/freescout-notifier/internal/channel"
+	"github.com/voicetel/freescout-notifier/internal/config"
+	"github.com/voicetel/freescout-notifier/internal/models"
+)
+
+var testNow = time.Date(2025, 1, 15, 16, 30, 0, 0, time.UTC)
+
+// request is a request received by the test endpoint
+type request struct {
+	header http.Header
+	body   []byte
+}
+
+func newTestEndpoint(t *testing.T) (*httptest.Server, <-chan request) {
+	t.Helper()
+
+	requests := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{header: r.Header, body: body}
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func testNotification() channel.Notification {
+	return channel.Notification{
+		Ticket: models.Ticket{
+			ID:                42,
+			Subject:           "Cannot log in",
+			AssignedUserName:  "John Smith ",
+			OwnerName:         "jane",
+			MinutesSinceReply: 90,
+			NotificationType:  models.OpenNoAgentResponse,
+		},
+		Status: models.StatusSent,
+	}
+}
+
+func TestPostSignsWithTimestamp(t *testing.T) {
+	server, requests := newTestEndpoint(t)
+	c := NewClient("hooks", config.WebhookConfig{URL: server.URL, Secret: "s3cret", Headers: map[string]string{"X-Team": "support"}})
+	c.now = func() time.Time { return testNow }
+
+	if err := c.Send(testNotification()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	r := <-requests
+
+	timestamp := r.header.Get(TimestampHeader)
+	if timestamp != "1736958600" {
+		t.Errorf("%s = %q, want %q", TimestampHeader, timestamp, "1736958600")
+	}
+	if got, want := r.header.Get(SignatureHeader), Sign([]byte("s3cret"), timestamp, r.body); got != want {
+		t.Errorf("%s = %q, want %q", SignatureHeader, got, want)
+	}
+	if r.header.Get("X-Team") != "support" {
+		t.Errorf("X-Team = %q, want support", r.header.Get("X-Team"))
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(r.body, &payload); err != nil {
+		t.Fatalf("unmarshal payload: %v", err)
+	}
+	if payload.Version != PayloadVersion || payload.Event != EventNotification {
+		t.Errorf("payload = (%d, %s), want (%d, %s)", payload.Version, payload.Event, PayloadVersion, EventNotification)
+	}
+	if payload.Ticket.ID != 42 || payload.Ticket.AssignedUserName != "John Smith" || payload.Ticket.OwnerName != "jane" {
+		t.Errorf("ticket = %+v", payload.Ticket)
+	}
+}
+
+func TestSignCoversTimestamp(t *testing.T) {
+	secret := []byte("s3cret")
+	body := []byte(`{"version":1}`)
+
+	signature := Sign(secret, "1736958600", body)
+	if Sign(secret, "1736958601", body) == signature {
+		t.Error("signature does not change with the timestamp")
+	}
+	if Sign(secret, "1736958600", []byte(`{"version":2}`)) == signature {
+		t.Error("signature does not change with the body")
+	}
+	if Sign([]byte("other"), "1736958600", body) == signature {
+		t.Error("signature does not change with the secret")
+	}
+}
+
+func TestPostUnsigned(t *testing.T) {
+	server, requests := newTestEndpoint(t)
+	c := NewClient("hooks", config.WebhookConfig{URL: server.URL, Unsigned: true})
+
+	if err := c.Send(testNotification()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	r := <-requests
+
+	if r.header.Get(SignatureHeader) != "" || r.header.Get(TimestampHeader) != "" {
+		t.Errorf("unsigned request carries %s %q and %s %q", SignatureHeader, r.header.Get(SignatureHeader),
+			TimestampHeader, r.header.Get(TimestampHeader))
+	}
+}
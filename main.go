@@ -1,15 +1,24 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"math/rand"
+	"net/http"
 	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/voicetel/freescout-notifier/internal/config"
 	"github.com/voicetel/freescout-notifier/internal/database"
 	"github.com/voicetel/freescout-notifier/internal/logging"
 	"github.com/voicetel/freescout-notifier/internal/models"
 	"github.com/voicetel/freescout-notifier/internal/notifier"
+	"github.com/voicetel/freescout-notifier/internal/server"
 )
 
 // Version information - these will be set at build time via ldflags
@@ -84,6 +93,15 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Slack interaction server mode
+	if cfg.Serve {
+		if err := serve(db, cfg, logger); err != nil {
+			logger.LogError("Interaction server failed", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	// Stats only mode
 	if cfg.StatsOnly {
 		if err := printStats(db, logger); err != nil {
@@ -102,7 +120,30 @@ func main() {
 	defer fsDB.Close()
 
 	// Create notifier
-	n := notifier.New(fsDB, db, cfg)
+	n, err := notifier.New(fsDB, db, cfg)
+	if err != nil {
+		logger.LogError("Failed to create notifier", err)
+		os.Exit(1)
+	}
+
+	// Digest mode
+	if cfg.SendDigest {
+		if err := n.SendDigest(); err != nil {
+			logger.LogError("Failed to send digest", err)
+			os.Exit(1)
+		}
+		fmt.Println("Digest sent successfully!")
+		os.Exit(0)
+	}
+
+	// Daemon mode
+	if cfg.RunDaemon {
+		if err := runDaemon(n, db, cfg, logger); err != nil {
+			logger.LogError("Daemon failed", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 
 	// Run notification check
 	stats, err := n.Run()
@@ -134,16 +175,28 @@ func checkConnections(cfg *config.Config, logger *logging.Logger) error {
 	if err != nil {
 		return fmt.Errorf("FreeScout connection failed: %w", err)
 	}
-	fsDB.Close()
+	defer fsDB.Close()
 	logger.Info("FreeScout database connection successful")
 
-	// Check Slack webhook
-	if cfg.Slack.WebhookURL != "" {
-		logger.Info("Testing Slack webhook...")
-		if err := notifier.TestSlackWebhook(cfg.Slack); err != nil {
-			return fmt.Errorf("Slack webhook test failed: %w", err)
+	// List the mailboxes left after the include/exclude filters
+	mailboxes, err := database.GetWatchedMailboxes(fsDB, notifier.Filter(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to list mailboxes: %w", err)
+	}
+	if len(mailboxes) == 0 {
+		logger.Warn("No mailboxes match the mailbox filters")
+	}
+	for _, m := range mailboxes {
+		logger.Info("Watching mailbox", "id", m.ID, "name", m.Name)
+	}
+
+	// Check notification channels
+	if len(cfg.ChannelConfigs()) > 0 {
+		logger.Info("Testing notification channels...")
+		if err := notifier.TestChannels(cfg); err != nil {
+			return fmt.Errorf("notification channel test failed: %w", err)
 		}
-		logger.Info("Slack webhook test successful")
+		logger.Info("Notification channel tests successful")
 	}
 
 	return nil
@@ -179,9 +232,20 @@ func printHumanReadableStats(stats map[string]interface{}) {
 
 	// By type
 	if typeMap, ok := stats["by_type"].(map[string]int); ok {
+		statusByType, _ := stats["by_type_status"].(map[string]map[string]int)
+
 		fmt.Printf("By Type:\n")
 		for notifType, count := range typeMap {
-			fmt.Printf("  %s: %d\n", notifType, count)
+			fmt.Printf("  %s: %d", notifType, count)
+			if statuses := statusByType[notifType]; len(statuses) > 0 {
+				var parts []string
+				for status, n := range statuses {
+					parts = append(parts, fmt.Sprintf("%s %d", status, n))
+				}
+				sort.Strings(parts)
+				fmt.Printf(" (%s)", strings.Join(parts, ", "))
+			}
+			fmt.Println()
 		}
 		fmt.Println()
 	}
@@ -222,11 +286,12 @@ func printHumanReadableStats(stats map[string]interface{}) {
 func printRunStats(stats *models.RunStats, logger *logging.Logger) {
 	// Use the logger's structured logging capability
 	logger.LogRunStats(map[string]interface{}{
-		"tickets_checked":      stats.TicketsChecked,
-		"notifications_sent":   stats.NotificationsSent,
-		"notifications_queued": stats.NotificationsQueued,
-		"errors":               stats.Errors,
-		"duration":             stats.Duration.String(),
+		"tickets_checked":        stats.TicketsChecked,
+		"notifications_sent":     stats.NotificationsSent,
+		"notifications_queued":   stats.NotificationsQueued,
+		"notifications_resolved": stats.NotificationsResolved,
+		"errors":                 stats.Errors,
+		"duration":               stats.Duration.String(),
 	})
 
 	// Also print human-readable format for console output
@@ -234,10 +299,82 @@ func printRunStats(stats *models.RunStats, logger *logging.Logger) {
 	fmt.Printf("Tickets checked: %d\n", stats.TicketsChecked)
 	fmt.Printf("Notifications sent: %d\n", stats.NotificationsSent)
 	fmt.Printf("Notifications queued: %d\n", stats.NotificationsQueued)
+	fmt.Printf("Notifications resolved: %d\n", stats.NotificationsResolved)
 	fmt.Printf("Errors: %d\n", stats.Errors)
 	fmt.Printf("Duration: %s\n", stats.Duration)
 }
 
+func serve(db *database.DB, cfg *config.Config, logger *logging.Logger) error {
+	srv := &http.Server{
+		Addr:              cfg.Server.ListenAddr,
+		Handler:           server.New(db, cfg.Server.SlackSigningSecret).Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		logger.Info("Slack interaction endpoint listening", "addr", cfg.Server.ListenAddr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		logger.Info("Shutting down interaction endpoint")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
+
+// runDaemon checks tickets every interval, plus a random jitter, reusing
+// the open database connections, and cleans up old records on its own
+// schedule. SIGINT or SIGTERM stops it once in-flight sends complete.
+func runDaemon(n *notifier.Notifier, db *database.DB, cfg *config.Config, logger *logging.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	logger.Info("Daemon started",
+		"interval", cfg.Daemon.Interval.String(),
+		"jitter", cfg.Daemon.Jitter.String(),
+		"cleanup_interval", cfg.Daemon.CleanupInterval.String(),
+	)
+
+	nextCleanup := time.Now().Add(cfg.Daemon.CleanupInterval.Duration)
+	for {
+		stats, err := n.RunContext(ctx)
+		if err != nil {
+			// Keep running; the next check may succeed once FreeScout is back
+			logger.LogError("Notification run failed", err)
+		} else if cfg.Stats || cfg.Verbose {
+			printRunStats(stats, logger)
+		}
+
+		if cfg.Daemon.CleanupInterval.Duration > 0 && !time.Now().Before(nextCleanup) && ctx.Err() == nil {
+			if err := performCleanup(db, cfg, logger); err != nil {
+				logger.LogError("Failed to perform cleanup", err)
+			}
+			nextCleanup = time.Now().Add(cfg.Daemon.CleanupInterval.Duration)
+		}
+
+		wait := cfg.Daemon.Interval.Duration
+		if jitter := cfg.Daemon.Jitter.Duration; jitter > 0 {
+			wait += time.Duration(rand.Int63n(int64(jitter)))
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info("Daemon stopped")
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
 func performCleanup(db *database.DB, cfg *config.Config, logger *logging.Logger) error {
 	logger.Info("Starting database cleanup",
 		"retention_days", cfg.RetentionDays,